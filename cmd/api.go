@@ -2,23 +2,28 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/Masterminds/semver/v3"
-	markdown "github.com/MichaelMure/go-term-markdown"
 	"github.com/dustin/go-humanize"
-	"github.com/ghodss/yaml"
 	"github.com/runar-rkmedia/go-common/logger"
-	"github.com/runar-rkmedia/go-common/utils"
+	commonUtils "github.com/runar-rkmedia/go-common/utils"
 	"github.com/runar-rkmedia/skiver/handlers"
 	"github.com/runar-rkmedia/skiver/models"
 	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
 )
 
 type Api struct {
@@ -29,115 +34,40 @@ type Api struct {
 	client   *http.Client
 	Headers  http.Header
 	DryRun   bool
+	// MaxRetries is the number of times an idempotent request is retried on
+	// temporary failures. Zero disables retries.
+	MaxRetries   int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// Cache is used for exports, if set.
+	Cache *ExportCache
+	// Offline makes exports use only the Cache.
+	Offline bool
+	// Replaying is set when responses are replayed from a recording. The version-check is then not cached.
+	Replaying bool
 }
 
-func NewAPI(l logger.AppLogger, endpoint string) Api {
-	c := http.Client{Timeout: time.Minute}
-	api := Api{
-		l:        l,
-		endpoint: strings.TrimSuffix(endpoint, "/"),
-		client:   &c,
-		Headers:  http.Header{},
-	}
-	if l.HasDebug() {
-		l.Debug().Str("uri", endpoint).Msg("Using skiver-api")
-	}
-	return api
+func init() {
+	rand.Seed(time.Now().UnixNano())
 }
 
-func (a *Api) verifyLatestVersion() {
-
-	if version == "" {
-		return
-	}
-	info, err := a.ServerInfo()
+func NewAPI(l logger.AppLogger, endpoint string, opts ClientOptions) (Api, error) {
+	c, err := newHTTPClient(opts)
 	if err != nil {
-		a.l.Error().Err(err).Msg("There was a problem fetching the server-info")
-		return
-	}
-	if info.Instance == "" && info.HostHash == "" {
-		a.l.Fatal().
-			Str("endpoint", a.endpoint).
-			Msg("This does not look like a Skiver-api...")
-	}
-	y, _ := yaml.Marshal(info)
-	fmt.Println(string(y))
-	currentCli := semver.MustParse(version)
-	if info.LatestCliRelease != nil && info.LatestCliRelease.TagName != "" {
-		if v, err := semver.NewVersion(info.LatestCliRelease.TagName); err == nil {
-			v.GreaterThan(currentCli)
-			if info.LatestCliRelease.Body != "" {
-				result := markdown.Render(fmt.Sprintf("---\n\n🎉🎉🎉 version %s of Skiver-CLI is available 🎉🎉🎉 \n\n%s\n\n---\n\nGet it from %s",
-					info.LatestCliRelease.TagName,
-					info.LatestCliRelease.Body,
-					info.LatestCliRelease.HTMLURL,
-				), 80, 2)
-				fmt.Println(string(result))
-			}
-		} else {
-			a.l.Warn().
-				Err(err).
-				Str("newversion", info.LatestCliRelease.TagName).
-				Msg("There was a problem parsing the TagName for the release")
-		}
+		return Api{}, err
 	}
-	if info.LatestCliRelease != nil && info.LatestRelease.TagName != "" && info.Version != "" {
-		if currentApi, err := semver.NewVersion(info.Version); err == nil {
-			if v, err := semver.NewVersion(info.LatestRelease.TagName); err == nil {
-				v.GreaterThan(currentApi)
-				// a.l.Info().
-				// 	Str("newversion", info.LatestCliRelease.TagName).
-				// 	Str("url", info.LatestCliRelease.HTMLURL).
-				// 	Str("changelog", info.LatestCliRelease.Body).
-				// 	Msg("There is a new version available. Please upgrade.")
-				if info.LatestCliRelease.Body != "" {
-					result := markdown.Render(fmt.Sprintf("---\n\n🎉🎉🎉 version %s of Skiver-API is available 🎉🎉🎉 \n\n%s\n\n---",
-						info.LatestRelease.TagName,
-						info.LatestRelease.Body,
-					), 80, 2)
-					fmt.Println(string(result))
-				}
-			} else {
-				a.l.Warn().
-					Err(err).
-					Str("newversion", info.LatestRelease.TagName).
-					Msg("There was a problem parsing the TagName for the servers latest release")
-			}
-		} else {
-			a.l.Warn().
-				Err(err).
-				Str("newversion", info.Version).
-				Msg("There was a problem parsing the Version for the server")
-		}
-	}
-
-	if info.MinCliVersion != "" {
-		if v, err := semver.NewVersion(info.MinCliVersion); err == nil {
-			v.GreaterThan(currentCli)
-			// a.l.Info().
-			// 	Str("newversion", info.LatestCliRelease.TagName).
-			// 	Str("url", info.LatestCliRelease.HTMLURL).
-			// 	Str("changelog", info.LatestCliRelease.Body).
-			// 	Msg("There is a new version available. Please upgrade.")
-			if info.LatestCliRelease.Body != "" {
-				result := markdown.Render(fmt.Sprintf("---\n\n🎉🎉🎉 version %s is avilable 🎉🎉🎉 \n\n%s\n\n---\n\nGet it from %s",
-					info.LatestCliRelease.TagName,
-					info.LatestCliRelease.Body,
-					info.LatestCliRelease.HTMLURL,
-				), 80, 2)
-				fmt.Println(string(result))
-			}
-		} else {
-			a.l.Warn().
-				Err(err).
-				Str("newversion", info.LatestCliRelease.TagName).
-				Msg("There was a problem parsing the TagName for the release")
-		}
+	api := Api{
+		l:            l,
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		client:       c,
+		Headers:      http.Header{},
+		RetryWaitMin: 500 * time.Millisecond,
+		RetryWaitMax: 30 * time.Second,
 	}
-	if info.MinCliVersion == "" {
-		return
+	if l.HasDebug() {
+		l.Debug().Str("uri", endpoint).Msg("Using skiver-api")
 	}
-
+	return api, nil
 }
 
 func (a *Api) SetToken(token string) {
@@ -147,7 +77,7 @@ func (a *Api) SetToken(token string) {
 	}
 	a.cookies = append(a.cookies, &c)
 }
-func (a *Api) Login(username, password string) error {
+func (a *Api) Login(ctx context.Context, username, password string) error {
 	if username == "" || password == "" {
 		return fmt.Errorf("Missing username/password")
 	}
@@ -156,21 +86,18 @@ func (a *Api) Login(username, password string) error {
 	if err != nil {
 		return fmt.Errorf("Failed to marshal login-payload: %w", err)
 	}
-	r, err := a.NewRequest(http.MethodPost, "/api/login/", bytes.NewBuffer(b))
+	r, err := a.NewRequest(ctx, http.MethodPost, "/api/login/", bytes.NewBuffer(b))
 	if err != nil {
 		return fmt.Errorf("failed to create login-request: %w", err)
 	}
 
-	res, err := http.DefaultClient.Do(r)
-	if err != nil {
-		return fmt.Errorf("login-request failed: %w", err)
-	}
 	var j types.LoginResponse
-	res, err = a.Do(r, &j)
+	res, err := a.Do(r, &j)
 	if err != nil {
-		return fmt.Errorf("failed reading body of login-request: %w", err)
+		return fmt.Errorf("login-request failed: %w", err)
 	}
 	a.cookies = res.Cookies()
+	a.login = &j
 	if a.l.HasDebug() {
 		a.l.Debug().
 			Int("statusCode", res.StatusCode).
@@ -183,12 +110,57 @@ func (a *Api) Login(username, password string) error {
 
 }
 
-func (a Api) Import(projectName string, kind string, locale string, reader io.Reader, dryRun bool) (*http.Response, handlers.ImportResult, error) {
+// WithToken returns a copy of the Api, using only the token for authentication.
+func (a Api) WithToken(token string) Api {
+	a.cookies = nil
+	a.SetToken(token)
+	return a
+}
+
+// TokenCookie returns the cookie holding the session-token, if any.
+func (a Api) TokenCookie() *http.Cookie {
+	for _, c := range a.cookies {
+		if c.Name == "token" && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+// Session returns the current session, as reported by the server.
+func (a Api) Session(ctx context.Context) (types.LoginResponse, error) {
+	var j types.LoginResponse
+	r, err := a.NewRequest(ctx, http.MethodGet, "/api/login/", nil)
+	if err != nil {
+		return j, fmt.Errorf("failed to create session-request: %w", err)
+	}
+	_, err = a.Do(r, &j)
+	if err != nil {
+		return j, fmt.Errorf("session-request failed: %w", err)
+	}
+	return j, nil
+}
+
+// Logout invalidates the current session on the server.
+func (a Api) Logout(ctx context.Context) error {
+	r, err := a.NewRequest(ctx, http.MethodDelete, "/api/login/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create logout-request: %w", err)
+	}
+	res, err := a.Do(r, nil)
+	if err != nil {
+		return fmt.Errorf("logout-request failed: %w", err)
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (a Api) Import(ctx context.Context, projectName string, kind string, locale string, reader io.Reader, dryRun bool) (*http.Response, handlers.ImportResult, error) {
 	var j handlers.ImportResult
 	if len(a.cookies) == 0 {
-		return nil, j, fmt.Errorf("Not logged in")
+		return nil, j, ErrNotLoggedIn
 	}
-	r, err := a.NewRequest(http.MethodPost, "/api/import/"+kind+"/"+projectName+"/"+locale, reader)
+	r, err := a.NewRequest(ctx, http.MethodPost, "/api/import/"+kind+"/"+projectName+"/"+locale, reader)
 	if err != nil {
 		return nil, j, fmt.Errorf("failed to create import-request: %w", err)
 	}
@@ -214,23 +186,163 @@ func (a Api) Import(projectName string, kind string, locale string, reader io.Re
 
 }
 
-func (a Api) ServerInfo() (models.ServerInfo, error) {
+func (a Api) ServerInfo(ctx context.Context) (models.ServerInfo, error) {
 	var info models.ServerInfo
 
-	r, err := a.NewRequest(http.MethodGet, "/api/serverInfo/", nil)
+	r, err := a.NewRequest(ctx, http.MethodGet, "/api/serverInfo/", nil)
+	if err != nil {
+		return info, fmt.Errorf("failed to create serverInfo-request: %w", err)
+	}
+	_, err = a.Do(r, &info)
 	if err != nil {
-		a.l.Fatal().Err(err)
+		return info, fmt.Errorf("serverInfo-request failed: %w", err)
 	}
-	a.Do(r, &info)
 
 	return info, nil
 
 }
-func (a Api) Export(projectName string, format string, locale string, writer io.Writer) error {
+
+// Projects lists the projects available to the current user, keyed by id.
+func (a Api) Projects(ctx context.Context) (map[string]types.Project, error) {
+	var j map[string]types.Project
+	if len(a.cookies) == 0 {
+		return j, ErrNotLoggedIn
+	}
+	r, err := a.NewRequest(ctx, http.MethodGet, "/api/project/", nil)
+	if err != nil {
+		return j, fmt.Errorf("failed to create project-request: %w", err)
+	}
+	_, err = a.Do(r, &j)
+	if err != nil {
+		return j, fmt.Errorf("project-request failed: %w", err)
+	}
+	return j, nil
+}
+
+// ResolveProject finds the project by id or short-name. If it is not found,
+// the closest project-names are returned as suggestions.
+func (a Api) ResolveProject(ctx context.Context, nameLike string) (*types.Project, []string, error) {
+	projects, err := a.Projects(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidates := map[string]bool{}
+	for _, p := range projects {
+		if p.ID == nameLike || p.ShortName == nameLike {
+			p := p
+			return &p, nil, nil
+		}
+		if p.ShortName != "" {
+			candidates[p.ShortName] = true
+		}
+	}
+	return nil, closestMatches(nameLike, utils.SortedMapKeys(candidates), 3), nil
+}
+
+// ExportProject exports the project in the "raw" format.
+func (a Api) ExportProject(ctx context.Context, projectName string, locale string) (types.ExtendedProject, error) {
+	var ep types.ExtendedProject
+	buf := bytes.Buffer{}
+	err := a.Export(ctx, projectName, "raw", locale, &buf)
+	if err != nil {
+		return ep, err
+	}
+	err = json.Unmarshal(buf.Bytes(), &ep)
+	if err != nil {
+		return ep, fmt.Errorf("failed to unmarshal exported project: %w", err)
+	}
+	return ep, nil
+}
+
+func (a Api) CreateCategory(ctx context.Context, input models.CategoryInput) (types.Category, error) {
+	var j types.Category
+	err := a.sendJSON(ctx, http.MethodPost, "/api/category/", input, &j)
+	return j, err
+}
+
+func (a Api) CreateTranslation(ctx context.Context, input models.TranslationInput) (types.Translation, error) {
+	var j types.Translation
+	err := a.sendJSON(ctx, http.MethodPost, "/api/translation/", input, &j)
+	return j, err
+}
+
+func (a Api) DeleteTranslation(ctx context.Context, id string) error {
+	return a.sendJSON(ctx, http.MethodDelete, "/api/translation/"+url.PathEscape(id), nil, nil)
+}
+
+func (a Api) CreateTranslationValue(ctx context.Context, input models.TranslationValueInput) (types.TranslationValue, error) {
+	var j types.TranslationValue
+	err := a.sendJSON(ctx, http.MethodPost, "/api/translationValue/", input, &j)
+	return j, err
+}
+
+func (a Api) UpdateTranslationValue(ctx context.Context, input models.UpdateTranslationValueInput) (types.TranslationValue, error) {
+	var j types.TranslationValue
+	err := a.sendJSON(ctx, http.MethodPut, "/api/translationValue/", input, &j)
+	return j, err
+}
+
+// sendJSON sends payload (if not nil) as json, and unmarshals the response into j (if not nil).
+func (a Api) sendJSON(ctx context.Context, method string, subpath string, payload interface{}, j interface{}) error {
 	if len(a.cookies) == 0 {
-		return fmt.Errorf("Not logged in")
+		return ErrNotLoggedIn
+	}
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload for %s %s: %w", method, subpath, err)
+		}
+		body = bytes.NewReader(b)
+	}
+	r, err := a.NewRequest(ctx, method, subpath, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request for %s %s: %w", method, subpath, err)
 	}
-	r, err := a.NewRequest(http.MethodGet, "/api/export/", nil)
+	res, err := a.Do(r, j)
+	if err != nil {
+		return err
+	}
+	if j == nil {
+		res.Body.Close()
+	}
+	if a.l.HasDebug() {
+		a.l.Debug().
+			Int("statusCode", res.StatusCode).
+			Str("path", res.Request.URL.String()).
+			Str("method", res.Request.Method).
+			Bool("dry-run", a.DryRun).
+			Msg("Result of request")
+	}
+	return nil
+}
+
+// Export writes the exported project to writer.
+// If a Cache is set, the cached export is revalidated with the server, and used
+// as a fallback if the server cannot be reached. In Offline-mode, only the cache is used.
+func (a Api) Export(ctx context.Context, projectName string, format string, locale string, writer io.Writer) error {
+	var cacheKey string
+	var cached *cachedExport
+	if a.Cache != nil {
+		cacheKey = a.Cache.Key(a.endpoint, projectName, format, locale)
+		c, err := a.Cache.Get(cacheKey)
+		if err != nil {
+			a.l.Warn().Err(err).Str("cache-key", cacheKey).Msg("Failed to read export from cache")
+		}
+		cached = c
+	}
+	if a.Offline {
+		if cached == nil {
+			return fmt.Errorf("offline: no cached export for project '%s' with format '%s' and locale '%s'. Run the command once without --offline to populate the cache", projectName, format, locale)
+		}
+		a.l.Debug().Time("fetched-at", cached.FetchedAt).Msg("Using cached export (offline)")
+		_, err := writer.Write(cached.Body)
+		return err
+	}
+	if len(a.cookies) == 0 {
+		return ErrNotLoggedIn
+	}
+	r, err := a.NewRequest(ctx, http.MethodGet, "/api/export/", nil)
 	if err != nil {
 		return fmt.Errorf("failed to create export-request: %w", err)
 	}
@@ -239,14 +351,56 @@ func (a Api) Export(projectName string, format string, locale string, writer io.
 	q.Set("locale", locale)
 	q.Set("project", projectName)
 	r.URL.RawQuery = q.Encode()
+	if cached != nil {
+		if cached.ETag != "" {
+			r.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			r.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 
 	res, err := a.Do(r, nil)
 	if err != nil {
+		// A nil response means the server could not be reached at all, and a
+		// 5xx-response usually means that it is restarting behind a proxy.
+		var apiErr *ApiError
+		unavailable := res == nil || (errors.As(err, &apiErr) && apiErr.StatusCode >= 500)
+		if unavailable && cached != nil && ctx.Err() == nil {
+			a.l.Warn().
+				Err(err).
+				Time("fetched-at", cached.FetchedAt).
+				Msg("Failed to reach the server, using cached export")
+			_, err := writer.Write(cached.Body)
+			return err
+		}
 		return fmt.Errorf("export-request failed: %w", err)
 	}
 	defer res.Body.Close()
 
-	written, err := io.Copy(writer, res.Body)
+	if res.StatusCode == http.StatusNotModified {
+		if cached == nil {
+			return fmt.Errorf("export-request failed: server responded with %d, but there is no cached export to use", res.StatusCode)
+		}
+		if a.l.HasDebug() {
+			a.l.Debug().
+				Str("path", res.Request.URL.String()).
+				Time("fetched-at", cached.FetchedAt).
+				Msg("Export not modified, using cached export")
+		}
+		_, err := writer.Write(cached.Body)
+		return err
+	}
+
+	var body io.Reader = res.Body
+	buf := bytes.Buffer{}
+	if a.Cache != nil {
+		body = io.TeeReader(res.Body, &buf)
+	}
+	written, err := io.Copy(writer, body)
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
 	if a.l.HasDebug() {
 		a.l.Debug().
 			Int("statusCode", res.StatusCode).
@@ -256,12 +410,27 @@ func (a Api) Export(projectName string, format string, locale string, writer io.
 			Str("written-text", humanize.Bytes(uint64(written))).
 			Msg("Result of request")
 	}
+	if a.Cache != nil {
+		err := a.Cache.Set(cacheKey, cachedExport{
+			Endpoint:     a.endpoint,
+			Project:      projectName,
+			Format:       format,
+			Locale:       locale,
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+			Body:         buf.Bytes(),
+		})
+		if err != nil {
+			a.l.Warn().Err(err).Str("cache-key", cacheKey).Msg("Failed to write export to cache")
+		}
+	}
 	return nil
 
 }
 
-// NewRequest is a thin wrapper around http.NewRequest
-func (a *Api) NewRequest(method string, subpath string, body io.Reader) (*http.Request, error) {
+// NewRequest is a thin wrapper around http.NewRequestWithContext
+func (a *Api) NewRequest(ctx context.Context, method string, subpath string, body io.Reader) (*http.Request, error) {
 	uri := a.endpoint + subpath
 	if a.l.HasDebug() {
 		a.l.Debug().
@@ -270,15 +439,15 @@ func (a *Api) NewRequest(method string, subpath string, body io.Reader) (*http.R
 			Str("uri", uri).
 			Msg("Creating request")
 	}
-	r, err := http.NewRequest(method, uri, body)
+	r, err := http.NewRequestWithContext(ctx, method, uri, body)
 	if err != nil {
 		return r, err
 	}
+	r.Header = a.Headers.Clone()
+	r.Header.Set("Content-Type", "application/json")
 	if a.DryRun {
 		r.Header.Set("dry-run", "1")
 	}
-	r.Header = a.Headers.Clone()
-	r.Header.Set("Content-Type", "application/json")
 	for _, c := range a.cookies {
 		r.AddCookie(c)
 	}
@@ -289,7 +458,7 @@ func (a *Api) NewRequest(method string, subpath string, body io.Reader) (*http.R
 func (a Api) Do(r *http.Request, j interface{}) (*http.Response, error) {
 	reqId := r.Header.Get("X-Request-ID")
 	if reqId == "" {
-		id, err := utils.ForceCreateUniqueId()
+		id, err := commonUtils.ForceCreateUniqueId()
 		if err != nil {
 			a.l.Warn().Err(err).Str("unique-id", id).Msg("an error occured when attempting to create a unique id for the request.")
 		}
@@ -304,7 +473,7 @@ func (a Api) Do(r *http.Request, j interface{}) (*http.Response, error) {
 			Str("uri", r.URL.String()).
 			Msg("Doing request")
 	}
-	res, err := a.client.Do(r)
+	res, err := a.doWithRetries(r, reqId)
 	if err != nil {
 		return res, err
 	}
@@ -317,18 +486,24 @@ func (a Api) Do(r *http.Request, j interface{}) (*http.Response, error) {
 			Interface("status-code", res.StatusCode).
 			Msg("Result of request")
 	}
-	if res.StatusCode >= 300 {
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusNotModified {
 		defer res.Body.Close()
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return res, fmt.Errorf("failed reading body of request: %w", err)
 		}
+		apiErr := &ApiError{
+			StatusCode: res.StatusCode,
+			RequestID:  reqId,
+			Message:    string(body),
+		}
 		var j models.APIError
-		err = json.Unmarshal(body, &j)
-		if err == nil {
-			return res, fmt.Errorf("request returned %d-response: %s (%s) %#v", res.StatusCode, j.Error.Message, j.Error.Code, j.Details)
+		if err := json.Unmarshal(body, &j); err == nil {
+			apiErr.Message = j.Error.Message
+			apiErr.Code = fmt.Sprint(j.Error.Code)
+			apiErr.Details = j.Details
 		}
-		return res, fmt.Errorf("request returned %d-response: %s", res.StatusCode, string(body))
+		return res, apiErr
 	}
 	if j != nil {
 		defer res.Body.Close()
@@ -343,3 +518,120 @@ func (a Api) Do(r *http.Request, j interface{}) (*http.Response, error) {
 	}
 	return res, err
 }
+
+// doWithRetries performs the request, retrying idempotent requests on temporary
+// failures with exponential backoff and jitter.
+func (a Api) doWithRetries(r *http.Request, reqId string) (*http.Response, error) {
+	maxRetries := 0
+	if isIdempotentMethod(r.Method) {
+		maxRetries = a.MaxRetries
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := a.client.Do(r)
+		if attempt > maxRetries || r.Context().Err() != nil || !shouldRetry(res, err) {
+			return res, err
+		}
+		wait := a.retryWait(attempt, res)
+		ll := a.l.Warn().
+			Str("method", r.Method).
+			Str("request-id", reqId).
+			Str("uri", r.URL.String()).
+			Int("attempt", attempt).
+			Int("max-attempts", maxRetries+1).
+			Str("wait", wait.String())
+		if err != nil {
+			ll = ll.Err(err)
+		}
+		if res != nil {
+			ll = ll.Int("status-code", res.StatusCode)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		ll.Msg("Request failed, retrying")
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(wait):
+		}
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind body for retry: %w", err)
+			}
+			r.Body = body
+		}
+	}
+}
+
+// retryWait returns the duration to wait before the next attempt.
+// A Retry-After-header is honored, but never beyond RetryWaitMax.
+func (a Api) retryWait(attempt int, res *http.Response) time.Duration {
+	if res != nil && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+		if d, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			if d > a.RetryWaitMax {
+				return a.RetryWaitMax
+			}
+			return d
+		}
+	}
+	wait := a.RetryWaitMin << (attempt - 1)
+	if wait <= 0 || wait > a.RetryWaitMax {
+		wait = a.RetryWaitMax
+	}
+	// Use "equal jitter", so that we always wait at least half of the backoff.
+	half := wait / 2
+	if half <= 0 {
+		return wait
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return true
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return false
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportCache is an on-disk cache of exports, so that unchanged exports can be
+// revalidated with the server instead of downloaded, and be used when the
+// server is unreachable.
+type ExportCache struct {
+	Dir string
+}
+
+type cachedExport struct {
+	Endpoint     string    `json:"endpoint"`
+	Project      string    `json:"project"`
+	Format       string    `json:"format"`
+	Locale       string    `json:"locale"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         []byte    `json:"-"`
+}
+
+// NewExportCache creates an ExportCache in dir. If dir is empty, a directory
+// within the users cache-directory is used.
+func NewExportCache(dir string) (*ExportCache, error) {
+	if dir == "" {
+		userCache, err := defaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userCache, "exports")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create cache-directory %s: %w", dir, err)
+	}
+	return &ExportCache{Dir: dir}, nil
+}
+
+// defaultCacheDir returns the directory within the users cache-directory used by skiver-cli
+func defaultCacheDir() (string, error) {
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a cache-directory: %w", err)
+	}
+	return filepath.Join(userCache, "skiver-cli"), nil
+}
+
+// Key returns the cache-key for an export.
+func (c ExportCache) Key(endpoint, project, format, locale string) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{endpoint, project, format, locale}, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+func (c ExportCache) metaPath(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+func (c ExportCache) bodyPath(key string) string {
+	return filepath.Join(c.Dir, key+".body")
+}
+
+// Get returns the cached export for the key, or nil if there is no such entry.
+func (c ExportCache) Get(key string) (*cachedExport, error) {
+	b, err := os.ReadFile(c.metaPath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache-entry: %w", err)
+	}
+	var entry cachedExport
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cache-entry: %w", err)
+	}
+	entry.Body, err = os.ReadFile(c.bodyPath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read body of cache-entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// Set stores the export. The metadata is removed first, and written after the body,
+// since an entry without metadata is not considered to exist. Both are written
+// atomically, and readable only by the current user, since exports may be private.
+func (c ExportCache) Set(key string, entry cachedExport) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache-entry: %w", err)
+	}
+	if err := os.Remove(c.metaPath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cache-entry: %w", err)
+	}
+	if err := writeFileAtomic(c.bodyPath(key), entry.Body, 0600); err != nil {
+		return fmt.Errorf("failed to write body of cache-entry: %w", err)
+	}
+	if err := writeFileAtomic(c.metaPath(key), b, 0600); err != nil {
+		return fmt.Errorf("failed to write cache-entry: %w", err)
+	}
+	return nil
+}
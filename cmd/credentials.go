@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// credentialsStore holds sessions created with `skiver login`, keyed by the uri
+// of the skiver-instance.
+type credentialsStore struct {
+	path     string
+	Sessions map[string]storedSession `json:"sessions"`
+}
+
+type storedSession struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	Expires   time.Time `json:"expires,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s storedSession) Expired() bool {
+	return !s.Expires.IsZero() && time.Now().After(s.Expires)
+}
+
+func credentialsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "skiver", "credentials.json"), nil
+}
+
+func credentialsKey(uri string) string {
+	return strings.TrimSuffix(uri, "/")
+}
+
+func loadCredentials() (*credentialsStore, error) {
+	p, err := credentialsPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find path for credentials: %w", err)
+	}
+	store := credentialsStore{path: p, Sessions: map[string]storedSession{}}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &store, nil
+		}
+		return nil, fmt.Errorf("failed to read credentials from %s: %w", p, err)
+	}
+	if err := json.Unmarshal(b, &store); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal credentials from %s: %w", p, err)
+	}
+	if store.Sessions == nil {
+		store.Sessions = map[string]storedSession{}
+	}
+	return &store, nil
+}
+
+func (c *credentialsStore) Get(uri string) (storedSession, bool) {
+	s, ok := c.Sessions[credentialsKey(uri)]
+	return s, ok
+}
+func (c *credentialsStore) Set(uri string, s storedSession) {
+	c.Sessions[credentialsKey(uri)] = s
+}
+func (c *credentialsStore) Delete(uri string) bool {
+	key := credentialsKey(uri)
+	_, ok := c.Sessions[key]
+	delete(c.Sessions, key)
+	return ok
+}
+
+// Save writes the credentials to disk, readable only by the current user.
+// Nothing is written when replaying, since the recorded session-tokens are redacted.
+func (c *credentialsStore) Save() error {
+	if CLI.HTTPReplay != "" {
+		l.Warn().Str("http-replay", CLI.HTTPReplay).Msg("Replaying recorded responses, so the stored credentials are not changed")
+		return nil
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for credentials: %w", err)
+	}
+	// Written atomically, so that an existing file also gets its permissions tightened.
+	if err := writeFileAtomic(c.path, b, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials to %s: %w", c.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// Exit-codes used by the cli. These are part of the public contract, and
+// should not change.
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitUsage      = 2
+	ExitAuth       = 3
+	ExitNotFound   = 4
+	ExitValidation = 5
+	ExitNetwork    = 6
+	ExitServer     = 7
+	// ExitUnsupported is used when the cli is older than the MinCliVersion of the server
+	ExitUnsupported = 8
+	ExitUnusedKeys  = 10
+	ExitStale       = 11
+	// ExitInterrupted follows the convention of 128 + SIGINT
+	ExitInterrupted = 130
+)
+
+const exitCodesHelp = `Exit-codes:
+  0   Success
+  1   General error
+  2   Invalid usage, like unknown commands, invalid flags or an invalid configuration
+  3   Authentication or authorization failed
+  4   Not found, like an unknown project
+  5   Validation-error reported by the server
+  6   Network-error, the server could not be reached
+  7   Server-error
+  8   This version of the cli is not supported by the server, and must be upgraded
+  10  Unused translation-keys were found (unused)
+  11  Generated files are out of date (generate --check)
+  130 Interrupted, for instance by Ctrl-C`
+
+// ErrNotLoggedIn is returned for requests that require a session, when there is none.
+var ErrNotLoggedIn = errors.New("Not logged in")
+
+// ApiError is returned for requests that do not succeed.
+type ApiError struct {
+	StatusCode int
+	// Code is the error-code reported by the server, if any.
+	Code      string
+	Message   string
+	Details   interface{}
+	RequestID string
+}
+
+func (e *ApiError) Error() string {
+	if e.Code == "" && e.Details == nil {
+		return fmt.Sprintf("request returned %d-response: %s (request-id: %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("request returned %d-response: %s (%s) %#v (request-id: %s)", e.StatusCode, e.Message, e.Code, e.Details, e.RequestID)
+}
+
+// exitCode returns the exit-code matching the error.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+	if errors.Is(err, ErrNotLoggedIn) {
+		return ExitAuth
+	}
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+			return ExitAuth
+		case apiErr.StatusCode == http.StatusNotFound:
+			return ExitNotFound
+		case apiErr.StatusCode == http.StatusBadRequest, apiErr.StatusCode == http.StatusUnprocessableEntity, apiErr.StatusCode == http.StatusConflict:
+			return ExitValidation
+		case apiErr.StatusCode >= 500:
+			return ExitServer
+		}
+		return ExitError
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	if errors.As(err, &urlErr) || errors.As(err, &netErr) {
+		return ExitNetwork
+	}
+	return ExitError
+}
+
+// exitEvent is a log-event at fatal-level, which exits with the exit-code
+// matching the error when sent.
+type exitEvent struct {
+	e    *zerolog.Event
+	code int
+}
+
+// fatal should be used instead of l.Fatal() for errors that may come from the Api,
+// so that the exit-code reflects the error.
+func fatal(err error) *exitEvent {
+	return fatalWith(l.Logger, err)
+}
+func fatalWith(ll zerolog.Logger, err error) *exitEvent {
+	return &exitEvent{e: ll.WithLevel(zerolog.FatalLevel).Err(err), code: exitCode(err)}
+}
+
+// fatalCode is like fatal, but exits with the given exit-code. It is used for
+// failures that are detected before any request is made. err may be nil.
+func fatalCode(code int, err error) *exitEvent {
+	return fatalCodeWith(l.Logger, code, err)
+}
+func fatalCodeWith(ll zerolog.Logger, code int, err error) *exitEvent {
+	e := ll.WithLevel(zerolog.FatalLevel)
+	if err != nil {
+		e = e.Err(err)
+	}
+	return &exitEvent{e: e, code: code}
+}
+
+func (e *exitEvent) Str(key, val string) *exitEvent {
+	e.e = e.e.Str(key, val)
+	return e
+}
+func (e *exitEvent) Strs(key string, vals []string) *exitEvent {
+	e.e = e.e.Strs(key, vals)
+	return e
+}
+func (e *exitEvent) Int(key string, i int) *exitEvent {
+	e.e = e.e.Int(key, i)
+	return e
+}
+func (e *exitEvent) Bool(key string, b bool) *exitEvent {
+	e.e = e.e.Bool(key, b)
+	return e
+}
+func (e *exitEvent) Interface(key string, i interface{}) *exitEvent {
+	e.e = e.e.Interface(key, i)
+	return e
+}
+func (e *exitEvent) Msg(msg string) {
+	e.e.Msg(msg)
+	os.Exit(e.code)
+}
+func (e *exitEvent) Msgf(format string, v ...interface{}) {
+	e.e.Msgf(format, v...)
+	os.Exit(e.code)
+}
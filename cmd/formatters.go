@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/runar-rkmedia/skiver/types"
+)
+
+// localFormatter renders a format from the raw export of a project, so that
+// formats can be added without upgrading the server.
+type localFormatter struct {
+	Description string
+	Format      func(ep types.ExtendedProject, locale types.Locale, opts formatOptions) ([]byte, error)
+}
+
+// formatOptions are the target-specific options available to local formatters
+type formatOptions struct {
+	// Path is the output-path of the target. It may be a path-template, or empty when writing to stdout.
+	Path string
+	// Split is set when every top-level category is rendered as a separate file
+	Split bool
+	// UsedIn is a directory of source-files. Only the translations used there are rendered.
+	UsedIn string
+	// Keep are prefixes of keys to keep when tree-shaking, even if they are not found within the source-files
+	Keep []string
+	// DefaultLocale is the configured locale, used as the fallback by formats that embed every locale
+	DefaultLocale string
+}
+
+// localFormatters is the registry of formats rendered by the cli. Formats not
+// within the registry are exported by the server.
+var localFormatters = map[string]localFormatter{
+	"go": {
+		Description: "A go-package with typed key-constants, the catalogs of every locale, and a lookup-function with fallback",
+		Format:      formatGo,
+	},
+	"i18next": {
+		Description: "i18next-compatible nested json",
+		Format: func(ep types.ExtendedProject, locale types.Locale, _ formatOptions) ([]byte, error) {
+			nested, err := nestKeys(localeValues(ep, locale))
+			if err != nil {
+				return nil, err
+			}
+			return marshalJSON(nested)
+		},
+	},
+	"json-flat": {
+		Description: "Flat json, with dot-delimited keys",
+		Format: func(ep types.ExtendedProject, locale types.Locale, _ formatOptions) ([]byte, error) {
+			return marshalJSON(localeValues(ep, locale))
+		},
+	},
+	"typescript-typed": {
+		Description: "Typescript-constants for the keys, with the interpolation-parameters of every key as types",
+		Format:      formatTypescriptTyped,
+	},
+	"yaml": {
+		Description: "Nested yaml",
+		Format: func(ep types.ExtendedProject, locale types.Locale, _ formatOptions) ([]byte, error) {
+			nested, err := nestKeys(localeValues(ep, locale))
+			if err != nil {
+				return nil, err
+			}
+			return yaml.Marshal(nested)
+		},
+	},
+}
+
+// localFormatNames returns the names of the local formatters, sorted.
+func localFormatNames() []string {
+	names := make([]string, 0, len(localFormatters))
+	for k := range localFormatters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatLocally renders the format for the locale from the raw export.
+func formatLocally(ep types.ExtendedProject, format string, localeLike string, opts formatOptions) ([]byte, error) {
+	f, ok := localFormatters[format]
+	if !ok {
+		return nil, fmt.Errorf("no local formatter for '%s'", format)
+	}
+	locale, ok := findLocale(ep, localeLike)
+	if !ok {
+		return nil, fmt.Errorf("locale '%s' was not found within the project. Available locales: %s", localeLike, formatLocales(ep))
+	}
+	return f.Format(ep, locale, opts)
+}
+
+// localeValues returns the values for the locale, keyed by the full dot-delimited translation-key.
+// Contexts are added as 'key_context', as used by i18next.
+func localeValues(ep types.ExtendedProject, locale types.Locale) map[string]string {
+	values := map[string]string{}
+	walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+		for _, t := range c.Translations {
+			key := t.Key
+			if c.Key != "" {
+				key = c.Key + "." + t.Key
+			}
+			for _, tv := range t.Values {
+				if tv.LocaleID != locale.ID {
+					continue
+				}
+				if tv.Value != "" {
+					values[key] = tv.Value
+				}
+				for ctx, v := range tv.Context {
+					values[key+"_"+ctx] = v
+				}
+			}
+		}
+	})
+	return values
+}
+
+// nestKeys converts dot-delimited keys into nested maps.
+func nestKeys(flat map[string]string) (map[string]interface{}, error) {
+	nested := map[string]interface{}{}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts := strings.Split(k, ".")
+		m := nested
+		for i, p := range parts[:len(parts)-1] {
+			switch child := m[p].(type) {
+			case nil:
+				next := map[string]interface{}{}
+				m[p] = next
+				m = next
+			case map[string]interface{}:
+				m = child
+			default:
+				return nil, fmt.Errorf("the key '%s' is both a translation and a category", strings.Join(parts[:i+1], "."))
+			}
+		}
+		last := parts[len(parts)-1]
+		if _, ok := m[last]; ok {
+			return nil, fmt.Errorf("the key '%s' is both a translation and a category", k)
+		}
+		m[last] = flat[k]
+	}
+	return nested, nil
+}
+
+// marshalJSON marshals with indentation, and without escaping html-characters, which are common in translations.
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -23,76 +23,565 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
 
+	"github.com/rs/zerolog"
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
 	"github.com/spf13/cobra"
 )
 
-// generateCmd represents the generate command
-var generateCmd = &cobra.Command{
-	Use:   "generate",
-	Short: "Generate files for the project",
-	Run: func(cmd *cobra.Command, args []string) {
-		api := requireApi(false)
-		// var buf io.Writer
-		buf := &bytes.Buffer{}
-		format := CLI.Generate.Format
-		locale := CLI.Locale
-		if locale == "" {
-			l.Fatal().Msg("Locale is required")
+// maxConcurrentExports limits the number of exports running at the same time
+const maxConcurrentExports = 4
+
+// generatedFile is the output of an export for a single locale
+type generatedFile struct {
+	Target string
+	Locale string
+	// Category is the namespace, when splitting the output per top-level category
+	Category string
+	Path     string
+	Content  []byte
+	// Changed is set if the file was written, as opposed to being unchanged
+	Changed bool
+}
+
+// pathTemplateData is available within the path-template, like 'public/locales/{{.Locale}}/{{.Project}}.json'
+type pathTemplateData struct {
+	Locale  string
+	Project string
+	Format  string
+	// Category is the namespace, when splitting the output per top-level category
+	Category string
+}
+
+// parseLocales splits a comma-separated list of locales. The special value 'all' is expanded to all the locales within the project.
+func parseLocales(ctx context.Context, api Api, project, localeList string) ([]string, error) {
+	var locales []string
+	seen := map[string]bool{}
+	for _, s := range strings.Split(localeList, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
 		}
-		if CLI.Project == "" {
-			l.Fatal().Msg("Project is required")
+		seen[s] = true
+		if s != "all" {
+			locales = append(locales, s)
+			continue
 		}
-		if CLI.Generate.Format == "" {
-			l.Fatal().Msg("Format is required")
+		ep, err := api.ExportProject(ctx, project, "")
+		if err != nil {
+			return nil, err
+		}
+		var all []string
+		for _, loc := range ep.Locales {
+			all = append(all, loc.IETF)
 		}
-		ll := l.Debug().Str("project", CLI.Project).
-			Str("format", format)
+		sort.Strings(all)
+		for _, loc := range all {
+			if !seen[loc] {
+				seen[loc] = true
+				locales = append(locales, loc)
+			}
+		}
+	}
+	return locales, nil
+}
+
+// expandPath executes the path-template for the locale.
+func expandPath(tmpl *template.Template, data pathTemplateData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
 
-		if l.HasDebug() {
-			ll.Msg("Generating file")
+// renderLocalesLocally renders every locale with the render-function, from a single raw export.
+// If opts.Split is set, every top-level category is rendered as a separate file.
+// If opts.UsedIn is set, only the translations used within the source-files there are rendered.
+func renderLocalesLocally(ctx context.Context, api Api, project string, locales []string, opts formatOptions, render func(ep types.ExtendedProject, locale string) ([]byte, error)) ([]generatedFile, error) {
+	ep, err := api.ExportProject(ctx, project, "")
+	if err != nil {
+		return nil, err
+	}
+	if opts.UsedIn != "" {
+		filter, err := usedKeyFilter(ctx, ep, opts.UsedIn, opts.Keep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the translation-keys used within %s: %w", opts.UsedIn, err)
 		}
-		if format == "tKeys" {
-			// TODO: make an alias for this format on the server
-			// (don't have the time right now)
-			format = "typescript"
+		ep = shakeProject(ep, filter)
+	}
+	var files []generatedFile
+	for _, locale := range locales {
+		if !opts.Split {
+			b, err := render(ep, locale)
+			if err != nil {
+				return files, fmt.Errorf("failed to render locale %s: %w", locale, err)
+			}
+			files = append(files, generatedFile{Locale: locale, Content: b})
+			continue
 		}
-		// fmt.Println("writer", writer)
-		err := api.Export(CLI.Project, format, locale, buf)
+		namespaces, err := splitNamespaces(ep)
 		if err != nil {
-			l.Fatal().Err(err).Msg("Failed export")
+			return files, err
 		}
-		l.Debug().Msg("Export completed")
-		if CLI.Generate.Path == "" {
-			os.Stdout.Write(buf.Bytes())
-			return
+		for _, ns := range utils.SortedMapKeys(namespaces) {
+			b, err := render(namespaces[ns], locale)
+			if err != nil {
+				return files, fmt.Errorf("failed to render locale %s for the category %s: %w", locale, ns, err)
+			}
+			files = append(files, generatedFile{Locale: locale, Category: ns, Content: b})
 		}
-		if buf.Len() == 0 {
-			l.Fatal().Msg("No output generated")
+	}
+	return files, nil
+}
 
+// exportLocales exports the project for every locale concurrently. The results are in the same order as the locales.
+// Formats within the localFormatters are rendered by the cli, from a raw export.
+func exportLocales(ctx context.Context, api Api, project, format string, locales []string, opts formatOptions) ([]generatedFile, error) {
+	if _, ok := localFormatters[format]; ok {
+		l.Debug().Str("format", format).Msg("Using local formatter")
+		return renderLocalesLocally(ctx, api, project, locales, opts, func(ep types.ExtendedProject, locale string) ([]byte, error) {
+			return formatLocally(ep, format, locale, opts)
+		})
+	}
+	if opts.Split || opts.UsedIn != "" {
+		return nil, fmt.Errorf("the format %s cannot be split or tree-shaken, since it is exported by the server. Use a local format, or a template", format)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	files := make([]generatedFile, len(locales))
+	errs := make([]error, len(locales))
+	sem := make(chan struct{}, maxConcurrentExports)
+	wg := sync.WaitGroup{}
+	for i, locale := range locales {
+		wg.Add(1)
+		go func(i int, locale string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			buf := &bytes.Buffer{}
+			if err := api.Export(ctx, project, format, locale, buf); err != nil {
+				errs[i] = fmt.Errorf("export of locale %s failed: %w", locale, err)
+				cancel()
+				return
+			}
+			l.Debug().Str("locale", locale).Int("bytes", buf.Len()).Msg("Export completed")
+			files[i] = generatedFile{Locale: locale, Content: buf.Bytes()}
+		}(i, locale)
+	}
+	wg.Wait()
+	// Prefer the error that caused the cancellation
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return files, err
 		}
-		err = os.WriteFile(CLI.Generate.Path, buf.Bytes(), 0644)
+	}
+	for _, err := range errs {
 		if err != nil {
-			l.Fatal().Err(err).
-				Str("path", CLI.Generate.Path).
-				Msg("Failed during write to file")
+			return files, err
 		}
-		l.Info().Msg("Successful export")
-		if CLI.WithPrettier && CLI.Generate.Path != "" {
-			out, err := runPrettier(CLI.Generate.Path, nil)
+	}
+	return files, nil
+}
+
+// targetsFromConfig returns the targets to generate. If a name is given, only that target is returned.
+// Without any configured targets, or when a format is set with a flag, a target is created from the flags.
+func targetsFromConfig(cmd *cobra.Command, name string) ([]GenerateTarget, error) {
+	if name != "" {
+		var names []string
+		for _, t := range CLI.Generate.Targets {
+			if t.Name == name {
+				return []GenerateTarget{t}, nil
+			}
+			names = append(names, t.Name)
+		}
+		if suggestions := closestMatches(name, names, 3); len(suggestions) > 0 {
+			return nil, fmt.Errorf("no target named '%s'. Did you mean: %s?", name, strings.Join(suggestions, ", "))
+		}
+		return nil, fmt.Errorf("no target named '%s'. Available targets: %s", name, strings.Join(names, ", "))
+	}
+	if len(CLI.Generate.Targets) == 0 || cmd.Flags().Changed("format") || cmd.Flags().Changed("template") {
+		return []GenerateTarget{{
+			Format:     CLI.Generate.Format,
+			Template:   CLI.Generate.Template,
+			Path:       CLI.Generate.Path,
+			Split:      CLI.Generate.Split,
+			SplitIndex: CLI.Generate.SplitIndex,
+			UsedIn:     CLI.Generate.UsedIn,
+			Keep:       CLI.Generate.Keep,
+		}}, nil
+	}
+	return CLI.Generate.Targets, nil
+}
+
+// validateTarget applies the global defaults to the target, and parses the path-template
+func validateTarget(t *GenerateTarget) (*template.Template, error) {
+	if t.Project == "" {
+		t.Project = CLI.Project
+	}
+	if t.Locale == "" {
+		t.Locale = CLI.Locale
+	}
+	if t.WithPrettier == nil {
+		t.WithPrettier = &CLI.WithPrettier
+	}
+	if t.Locale == "" {
+		return nil, fmt.Errorf("Locale is required")
+	}
+	if t.Project == "" {
+		return nil, fmt.Errorf("Project is required")
+	}
+	if t.Template != "" {
+		if t.Format != "" && t.Format != "template" {
+			return nil, fmt.Errorf("Format and Template cannot be used at the same time")
+		}
+		t.Format = "template"
+	}
+	if t.Format == "" {
+		return nil, fmt.Errorf("Format is required")
+	}
+	if t.Split && !strings.Contains(t.Path, ".Category") {
+		return nil, fmt.Errorf("a path-template containing {{.Category}} is required when splitting")
+	}
+	if t.Split || t.UsedIn != "" {
+		if _, ok := localFormatters[t.Format]; !ok && t.Template == "" {
+			return nil, fmt.Errorf("the format %s cannot be split or tree-shaken, since it is exported by the server. Use one of the local formats %s, or a template", t.Format, strings.Join(localFormatNames(), ", "))
+		}
+	}
+	if t.Format == "go" {
+		// Every file would declare the same package-level identifiers, and embed every locale anyway
+		if t.Split {
+			return nil, fmt.Errorf("the format go cannot be split")
+		}
+		if strings.Contains(t.Locale, ",") || strings.TrimSpace(t.Locale) == "all" {
+			return nil, fmt.Errorf("the format go embeds every locale in a single file, so only a single locale can be set. It is used as the DefaultLocale")
+		}
+	}
+	if len(t.Keep) > 0 && t.UsedIn == "" {
+		return nil, fmt.Errorf("Keep can only be used together with UsedIn")
+	}
+	if t.Path == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(t.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the path-template '%s': %w", t.Path, err)
+	}
+	return tmpl, nil
+}
+
+// resolvePaths sets the path of every file, from the path-template
+func resolvePaths(t GenerateTarget, pathTmpl *template.Template, files []generatedFile) error {
+	if len(files) > 1 && !strings.Contains(t.Path, "{{") {
+		return fmt.Errorf("a path-template containing {{.Locale}} is required when generating multiple locales")
+	}
+	paths := map[string]string{}
+	for i, f := range files {
+		name := f.Locale
+		if f.Category != "" {
+			name += "/" + f.Category
+		}
+		if len(f.Content) == 0 {
+			return fmt.Errorf("no output generated for %s", name)
+		}
+		p, err := expandPath(pathTmpl, pathTemplateData{Locale: f.Locale, Project: t.Project, Format: t.Format, Category: f.Category})
+		if err != nil {
+			return fmt.Errorf("failed to execute the path-template '%s': %w", t.Path, err)
+		}
+		if other, ok := paths[p]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", other, name, p)
+		}
+		paths[p] = name
+		files[i].Path = p
+	}
+	return nil
+}
+
+// formatExtensions are the file-extensions of the formats, used to canonicalize and
+// run prettier on output written to stdout, where there is no path to infer it from.
+var formatExtensions = map[string]string{
+	"go":               ".go",
+	"i18n":             ".json",
+	"i18next":          ".json",
+	"json-flat":        ".json",
+	"typescript":       ".ts",
+	"typescript-typed": ".ts",
+	"yaml":             ".yaml",
+}
+
+// formatGeneratedFile returns the contents of the file as it would be written, with prettier applied if enabled.
+// JSON and YAML are canonicalized first, so that the output does not depend on the order from the server.
+func formatGeneratedFile(t GenerateTarget, f generatedFile) []byte {
+	if b, err := canonicalize(f.Path, f.Content); err != nil {
+		l.Warn().Err(err).Str("path", f.Path).Msg("Failed to canonicalize the output")
+	} else {
+		f.Content = b
+	}
+	if t.WithPrettier == nil || !*t.WithPrettier {
+		return f.Content
+	}
+	out, err := runPrettier(f.Path, bytes.NewReader(f.Content))
+	if err != nil {
+		l.Error().Err(err).Str("out", string(out)).Str("path", f.Path).Msg("Failed to run prettier on output")
+		return f.Content
+	}
+	if len(out) == 0 {
+		return f.Content
+	}
+	return out
+}
+
+// writeGeneratedFile writes the file atomically, and runs any post-processing on it.
+// The mode of an existing file is kept, and the file is not touched if the content is unchanged.
+func writeGeneratedFile(t GenerateTarget, f generatedFile) (changed bool, err error) {
+	if dir := filepath.Dir(f.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, fmt.Errorf("failed to create directory for %s: %w", f.Path, err)
+		}
+	}
+	var mode os.FileMode = 0644
+	if info, err := os.Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+		if existing, err := os.ReadFile(f.Path); err == nil && bytes.Equal(existing, f.Content) {
+			l.Debug().Str("path", f.Path).Msg("Content is unchanged, skipping write")
+			return false, nil
+		}
+	}
+	if err := writeFileAtomic(f.Path, f.Content, mode); err != nil {
+		return false, fmt.Errorf("failed during write to file %s: %w", f.Path, err)
+	}
+	if t.PostProcess != "" {
+		if _, err := runCmd(t.PostProcess, f.Path, nil); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// checkGeneratedFile compares the file with the one on disk, and returns a unified diff if they differ.
+func checkGeneratedFile(f generatedFile) (string, error) {
+	existing, err := os.ReadFile(f.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+	return unifiedDiff(f.Path, f.Path+" (generated)", existing, f.Content, 3), nil
+}
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate [target]",
+	Short: "Generate files for the project",
+	Long: `Generate files for the project.
+
+Targets can be declared in the config-file, and are all generated with a bare 'skiver generate'.
+A single target is generated with 'skiver generate <name>'. For example, in skiver-cli.toml:
+
+    [[generate.targets]]
+    name = "i18n"
+    format = "i18n"
+    locale = "all"
+    path = "public/locales/{{.Locale}}/{{.Project}}.json"
+
+    [[generate.targets]]
+    name = "keys"
+    format = "tKeys"
+    locale = "en"
+    path = "src/tKeys.ts"
+    with_prettier = true
+
+Multiple locales can be generated at once, by setting locale to a comma-separated list, or 'all'.
+The path is then a template, like 'public/locales/{{.Locale}}/{{.Project}}.json'.
+Available fields are .Locale, .Project and .Format.
+
+With --split, a file is written per top-level category and locale, like 'locales/{{.Locale}}/{{.Category}}.json',
+for use as i18next-namespaces. Translations without a category are written to the namespace 'translation'.
+An index of the namespaces and locales is written to 'namespaces.json' next to the files, or to --split-index.
+Splitting requires a local format, or a template.
+
+With --used-in <dir>, the output is tree-shaken: only the translation-keys referenced within the
+source-files in dir are included, using the same matching as 'skiver unused'. Keys used dynamically,
+like t('errors.' + code), can be kept with --keep errors. (a prefix), which may be repeated.
+Tree-shaking requires a local format, or a template.
+
+The formats ` + strings.Join(localFormatNames(), ", ") + ` are rendered by the cli from the raw export.
+Other formats are exported by the server.
+
+With --template, the output is rendered by a go-template instead. The template has access to
+.Project (the raw export), .Locale, .Locales, .Translations (key -> locale -> value), .Keys and .Values
+(key -> value for the current locale). In addition to the sprig-functions, the helpers
+lowerCamel, upperCamel, snake, upperSnake, kebab, upperKebab, jsonString, goString, escapeHTML,
+escapeXML, csvField, shellQuote, value <key>, valueFor <locale> <key> and locale <locale> are available.
+For example, an env-file:
+
+    {{range $key := .Keys}}{{upperSnake $key}}={{shellQuote (value $key)}}
+    {{end}}
+
+With --check, nothing is written. Instead, the generated files are compared with the files on disk,
+and a unified diff is printed for any differences. The exit-code is then 11, which is useful in CI.`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		for _, t := range CLI.Generate.Targets {
+			names = append(names, t.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		targets, err := targetsFromConfig(cmd, name)
+		if err != nil {
+			fatalCode(ExitNotFound, err).Msg("Unknown target")
+		}
+		pathTmpls := make([]*template.Template, len(targets))
+		outputTmpls := make([]*template.Template, len(targets))
+		for i := range targets {
+			pathTmpls[i], err = validateTarget(&targets[i])
+			if err != nil {
+				fatalCode(ExitUsage, err).Str("target", targets[i].Name).Msg("Invalid target")
+			}
+			if targets[i].Template != "" {
+				outputTmpls[i], err = parseGenerateTemplate(targets[i].Template)
+				if err != nil {
+					fatalCode(ExitUsage, err).Str("target", targets[i].Name).Msg("Invalid template")
+				}
+			}
+			if pathTmpls[i] == nil && len(targets) > 1 {
+				fatalCode(ExitUsage, nil).Str("target", targets[i].Name).Msg("A path is required when generating multiple targets")
+			}
+			if pathTmpls[i] == nil && CLI.Generate.Check {
+				fatalCode(ExitUsage, nil).Str("target", targets[i].Name).Msg("A path is required for check")
+			}
+		}
+		api := requireApi(ctx, false)
+		var written []generatedFile
+		var stale []string
+		for i, t := range targets {
+			locales, err := parseLocales(ctx, *api, t.Project, t.Locale)
 			if err != nil {
-				l.Error().Err(err).Str("out", string(out)).Msg("Failed to run prettier on output")
+				fatalExportError(ctx, l, *api, t.Project, err, "Failed to list the locales of the project")
+			}
+			if len(locales) == 0 {
+				fatalCode(ExitUsage, nil).Str("target", t.Name).Str("locale", t.Locale).Msg("No locales to generate")
+			}
+			if pathTmpls[i] == nil && len(locales) > 1 {
+				fatalCode(ExitUsage, nil).Strs("locales", locales).Msg("A path-template containing {{.Locale}} is required when generating multiple locales")
+			}
+			format := t.Format
+			if l.HasDebug() {
+				l.Debug().
+					Str("target", t.Name).
+					Str("project", t.Project).
+					Strs("locales", locales).
+					Str("format", format).
+					Msg("Generating file")
+			}
+			if format == "tKeys" {
+				// TODO: make an alias for this format on the server
+				// (don't have the time right now)
+				format = "typescript"
+			}
+			opts := formatOptions{Path: t.Path, Split: t.Split, UsedIn: t.UsedIn, Keep: t.Keep, DefaultLocale: t.Locale}
+			var files []generatedFile
+			if outputTmpls[i] != nil {
+				files, err = renderLocalesLocally(ctx, *api, t.Project, locales, opts, func(ep types.ExtendedProject, locale string) ([]byte, error) {
+					return renderTemplate(outputTmpls[i], ep, locale)
+				})
+			} else {
+				files, err = exportLocales(ctx, *api, t.Project, format, locales, opts)
+			}
+			if err != nil {
+				fatalExportError(ctx, l, *api, t.Project, err, "Failed export")
+			}
+			if pathTmpls[i] == nil {
+				f := files[0]
+				f.Path = "stdout" + formatExtensions[format]
+				os.Stdout.Write(formatGeneratedFile(t, f))
+				return
+			}
+			if err := resolvePaths(t, pathTmpls[i], files); err != nil {
+				l.Fatal().Err(err).Str("target", t.Name).Msg("Failed to resolve the output-paths")
+			}
+			if t.Split {
+				index, err := buildNamespaceIndex(files)
+				if err != nil {
+					l.Fatal().Err(err).Str("target", t.Name).Msg("Failed to build the index of namespaces")
+				}
+				files = append(files, generatedFile{Path: splitIndexPath(t), Content: index})
+			}
+			if CLI.Generate.Check && t.PostProcess != "" {
+				l.Warn().Str("target", t.Name).Str("post-process", t.PostProcess).Msg("The post-process-command is not applied when checking, which may cause false positives")
+			}
+			for _, f := range files {
+				if err := ctx.Err(); err != nil {
+					fatal(err).Msg("Generate was interrupted")
+				}
+				f.Target = t.Name
+				f.Content = formatGeneratedFile(t, f)
+				if CLI.Generate.Check {
+					diff, err := checkGeneratedFile(f)
+					if err != nil {
+						l.Fatal().Err(err).Str("target", t.Name).Str("path", f.Path).Msg("Failed to check the generated file")
+					}
+					if diff != "" {
+						os.Stdout.WriteString(diff)
+						stale = append(stale, f.Path)
+					}
+					continue
+				}
+				changed, err := writeGeneratedFile(t, f)
+				if err != nil {
+					l.Fatal().Err(err).Str("target", t.Name).Str("path", f.Path).Msg("Failed to write the generated file")
+				}
+				f.Changed = changed
+				written = append(written, f)
+			}
+		}
+		if CLI.Generate.Check {
+			if len(stale) > 0 {
+				l.WithLevel(zerolog.FatalLevel).Strs("paths", stale).Msg("Generated files are out of date. Run 'skiver generate' to update them")
+				os.Exit(ExitStale)
+			}
+			l.Info().Msg("Generated files are up to date")
+			return
+		}
+		l.Info().Int("files", len(written)).Msg("Successful export")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TARGET\tLOCALE\tPATH\tBYTES\tSTATUS")
+		for _, f := range written {
+			status := "unchanged"
+			if f.Changed {
+				status = "written"
 			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", f.Target, f.Locale, f.Path, len(f.Content), status)
 		}
+		w.Flush()
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	s := reflect.TypeOf(CLI.Generate)
-	for _, v := range []string{"Format", "Path"} {
+	for _, v := range []string{"Format", "Path", "Check", "Template", "Split", "SplitIndex", "UsedIn", "Keep"} {
 		mustSetVar(s, v, generateCmd, "generate.")
 	}
 }
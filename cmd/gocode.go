@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	goformat "go/format"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+	"github.com/stoewer/go-strcase"
+)
+
+// goPackageTemplate is the template for the go-format. The output is formatted with go/format.
+var goPackageTemplate = template.Must(template.New("go").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(`// Code generated by skiver-cli. DO NOT EDIT.
+
+// Package {{.Package}} contains the translations of the skiver-project {{.Project}}.
+package {{.Package}}
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Key is a translation-key
+type Key string
+
+// The translation-keys of the project
+const (
+{{- range .Keys}}
+	{{.Ident}} Key = {{quote .Key}}
+{{- end}}
+)
+
+// DefaultLocale is used when a key is missing within the requested locale.
+var DefaultLocale = {{quote .DefaultLocale}}
+
+// Locales are the IETF-tags of the locales within the catalog.
+var Locales = []string{ {{- range .Locales}}{{quote .}}, {{end -}} }
+
+// catalog holds the values of every locale. Contexts are stored as '<key>_<context>'.
+var catalog = map[string]map[Key]string{
+{{- range .Catalog}}
+	{{quote .Locale}}: {
+	{{- range .Entries}}
+		{{.Key}}: {{quote .Value}},
+	{{- end}}
+	},
+{{- end}}
+}
+
+// placeholder matches i18next-interpolations like {{"{{"}}name{{"}}"}} and {{"{{"}}value, number{{"}}"}}
+var placeholder = regexp.MustCompile(` + "`" + `{{"{{"}}-?\s*([^{}\s,]+)\s*(?:,[^{}]*)?{{"}}"}}` + "`" + `)
+
+// fallbacks returns the locales to try, like nb-NO, nb and then the DefaultLocale.
+func fallbacks(locale string) []string {
+	locales := []string{locale}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locales = append(locales, locale[:i])
+	}
+	return append(locales, DefaultLocale)
+}
+
+// Lookup returns the raw value of the key within the locale, falling back to
+// the base-language and then the DefaultLocale.
+func Lookup(locale string, key Key) (string, bool) {
+	for _, loc := range fallbacks(locale) {
+		if v, ok := catalog[loc][key]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// T returns the translation of the key within the locale, with placeholders
+// replaced by params. If the key is not found, the key itself is returned.
+func T(locale string, key Key, params map[string]interface{}) string {
+	v, ok := Lookup(locale, key)
+	if !ok {
+		return string(key)
+	}
+	return Interpolate(v, params)
+}
+
+// TContext is like T, but uses the value for the context, like 'male', if available.
+func TContext(locale string, key Key, context string, params map[string]interface{}) string {
+	if v, ok := Lookup(locale, key+Key("_"+context)); ok {
+		return Interpolate(v, params)
+	}
+	return T(locale, key, params)
+}
+
+// Interpolate replaces placeholders like {{"{{"}}name{{"}}"}} with the matching params.
+// Placeholders without a matching param are kept as is.
+func Interpolate(s string, params map[string]interface{}) string {
+	if len(params) == 0 {
+		return s
+	}
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		if v, ok := params[name]; ok {
+			return fmt.Sprint(v)
+		}
+		return m
+	})
+}
+`))
+
+type goKey struct {
+	Ident string
+	Key   string
+}
+
+type goCatalogEntry struct {
+	// Key is either the identifier of the constant, or a Key-conversion
+	Key   string
+	Value string
+}
+
+type goCatalog struct {
+	Locale  string
+	Entries []goCatalogEntry
+}
+
+// goIdent returns an exported go-identifier for the translation-key
+func goIdent(key string) string {
+	return "Key" + keyCase(strcase.UpperCamelCase)(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' {
+			return r
+		}
+		return '_'
+	}, key))
+}
+
+// goPackageName returns the package-name from the directory of the output-path,
+// or 'translations' if it cannot be used.
+func goPackageName(path string) string {
+	if path == "" || strings.Contains(path, "{{") {
+		return "translations"
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "translations"
+	}
+	name := strings.ToLower(strings.NewReplacer("-", "", ".", "", " ", "").Replace(filepath.Base(filepath.Dir(abs))))
+	if !token.IsIdentifier(name) || token.IsKeyword(name) {
+		return "translations"
+	}
+	return name
+}
+
+// formatGo renders a go-package with typed key-constants and the catalogs of all locales.
+// The configured locale is used as the DefaultLocale, falling back to the rendered locale.
+func formatGo(ep types.ExtendedProject, locale types.Locale, opts formatOptions) ([]byte, error) {
+	defaultLocale := locale.IETF
+	if opts.DefaultLocale != "" {
+		loc, ok := findLocale(ep, opts.DefaultLocale)
+		if !ok {
+			return nil, fmt.Errorf("the default locale '%s' was not found within the project. Available locales: %s", opts.DefaultLocale, formatLocales(ep))
+		}
+		defaultLocale = loc.IETF
+	}
+	idents := map[string]string{}
+	byIdent := map[string]string{}
+	walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+		for _, t := range c.Translations {
+			key := t.Key
+			if c.Key != "" {
+				key = c.Key + "." + t.Key
+			}
+			idents[key] = goIdent(key)
+		}
+	})
+	keys := utils.SortedMapKeys(idents)
+	data := struct {
+		Package       string
+		Project       string
+		DefaultLocale string
+		Locales       []string
+		Keys          []goKey
+		Catalog       []goCatalog
+	}{
+		Package:       goPackageName(opts.Path),
+		Project:       ep.ShortName,
+		DefaultLocale: defaultLocale,
+	}
+	for _, k := range keys {
+		ident := idents[k]
+		if other, ok := byIdent[ident]; ok {
+			return nil, fmt.Errorf("the keys '%s' and '%s' would both use the identifier %s", other, k, ident)
+		}
+		byIdent[ident] = k
+		data.Keys = append(data.Keys, goKey{Ident: ident, Key: k})
+	}
+
+	locales := make([]types.Locale, 0, len(ep.Locales))
+	for _, loc := range ep.Locales {
+		locales = append(locales, loc)
+	}
+	sort.Slice(locales, func(i, j int) bool { return locales[i].IETF < locales[j].IETF })
+	for _, loc := range locales {
+		data.Locales = append(data.Locales, loc.IETF)
+		values := localeValues(ep, loc)
+		cat := goCatalog{Locale: loc.IETF}
+		for _, k := range utils.SortedMapKeys(values) {
+			entry := goCatalogEntry{Key: idents[k], Value: values[k]}
+			if entry.Key == "" {
+				// Contexts do not have constants
+				entry.Key = "Key(" + strconv.Quote(k) + ")"
+			}
+			cat.Entries = append(cat.Entries, entry)
+		}
+		data.Catalog = append(data.Catalog, cat)
+	}
+
+	buf := bytes.Buffer{}
+	if err := goPackageTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	b, err := goformat.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the generated go-code: %w", err)
+	}
+	return b, nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ClientOptions configures the http-client used by the Api.
+type ClientOptions struct {
+	// CACert is a path to a PEM-encoded bundle of CAs, which are trusted in addition to the system-roots.
+	CACert string
+	// ClientCert and ClientKey are paths to a PEM-encoded certificate and key, used for mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables verification of the servers certificate.
+	InsecureSkipVerify bool
+	// Proxy is the url of a proxy. If empty, the proxy is read from the environment.
+	Proxy   string
+	Timeout time.Duration
+	// HTTPTrace is a path to a file where all requests and responses are recorded.
+	HTTPTrace string
+	// HTTPReplay is a path to a file recorded with HTTPTrace. Responses are served from it, instead of the server.
+	HTTPReplay string
+}
+
+func newHTTPClient(opts ClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA-bundle %s: %w", opts.CACert, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA-bundle %s", opts.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, fmt.Errorf("both a client-certificate and a client-key are required for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client-certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy-url %s: %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	var rt http.RoundTripper = transport
+	if opts.HTTPReplay != "" {
+		replay, err := newReplayTransport(opts.HTTPReplay)
+		if err != nil {
+			return nil, err
+		}
+		rt = replay
+	}
+	if opts.HTTPTrace != "" {
+		rec, err := newRecordingTransport(rt, opts.HTTPTrace)
+		if err != nil {
+			return nil, err
+		}
+		rt = rec
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return &http.Client{Timeout: timeout, Transport: rt}, nil
+}
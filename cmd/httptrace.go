@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceEntry is a single recorded request, with its response. It is written as a json-line.
+type traceEntry struct {
+	Time     time.Time      `json:"time"`
+	Duration string         `json:"duration"`
+	Request  traceRequest   `json:"request"`
+	Response *traceResponse `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+type traceRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type traceResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+}
+
+const redacted = "REDACTED"
+
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range sensitiveHeaders {
+		if _, ok := h[k]; ok {
+			h.Set(k, redacted)
+		}
+	}
+	return h
+}
+
+// recordingTransport records every request and response to w.
+type recordingTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func newRecordingTransport(next http.RoundTripper, path string) (*recordingTransport, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open http-trace-file %s: %w", path, err)
+	}
+	return &recordingTransport{next: next, w: f}, nil
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	entry := traceEntry{
+		Time: time.Now(),
+		Request: traceRequest{
+			Method: r.Method,
+			URL:    r.URL.String(),
+			Header: redactHeader(r.Header),
+		},
+	}
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		entry.Request.Body = string(b)
+		// The login-request contains the password
+		if strings.HasPrefix(r.URL.Path, "/api/login") {
+			entry.Request.Body = redacted
+		}
+	}
+	res, err := t.next.RoundTrip(r)
+	entry.Duration = time.Since(entry.Time).String()
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		b, rerr := io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(b))
+		if rerr != nil {
+			entry.Error = rerr.Error()
+		}
+		entry.Response = &traceResponse{
+			StatusCode: res.StatusCode,
+			Header:     redactHeader(res.Header),
+			Body:       string(b),
+		}
+	}
+	t.write(entry)
+	return res, err
+}
+
+func (t *recordingTransport) write(entry traceEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		l.Warn().Err(err).Msg("Failed to marshal http-trace")
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.w.Write(append(b, '\n')); err != nil {
+		l.Warn().Err(err).Msg("Failed to write http-trace")
+	}
+}
+
+// replayTransport serves responses from a file recorded with recordingTransport,
+// without contacting the server. Requests are matched by method, path and query,
+// in the order they were recorded. When all recordings for a request are used,
+// the last one is repeated.
+type replayTransport struct {
+	mu      sync.Mutex
+	entries map[string][]traceEntry
+}
+
+func replayKey(method string, requestURI string) string {
+	return method + " " + requestURI
+}
+
+// parseRequestURI returns the path and query of rawURL
+func parseRequestURI(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return u.RequestURI(), nil
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open http-replay-file %s: %w", path, err)
+	}
+	defer f.Close()
+	t := replayTransport{entries: map[string][]traceEntry{}}
+	scanner := bufio.NewScanner(f)
+	// Exports may be large
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry traceEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal line %d of http-replay-file %s: %w", line, path, err)
+		}
+		u, err := parseRequestURI(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url on line %d of http-replay-file %s: %w", line, path, err)
+		}
+		key := replayKey(entry.Request.Method, u)
+		t.entries[key] = append(t.entries[key], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read http-replay-file %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+func (t *replayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	key := replayKey(r.Method, r.URL.RequestURI())
+	t.mu.Lock()
+	entries := t.entries[key]
+	if len(entries) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("http-replay: no recorded response for %s", key)
+	}
+	entry := entries[0]
+	if len(entries) > 1 {
+		t.entries[key] = entries[1:]
+	}
+	t.mu.Unlock()
+
+	if entry.Response == nil {
+		return nil, fmt.Errorf("http-replay: recorded error for %s: %s", key, entry.Error)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.StatusCode, http.StatusText(entry.Response.StatusCode)),
+		StatusCode:    entry.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.Response.Header,
+		Body:          io.NopCloser(strings.NewReader(entry.Response.Body)),
+		ContentLength: int64(len(entry.Response.Body)),
+		Request:       r,
+	}, nil
+}
@@ -5,41 +5,241 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"os"
 	"reflect"
+	"strings"
 
+	"github.com/mattn/go-isatty"
+	"github.com/runar-rkmedia/skiver/handlers"
+	"github.com/runar-rkmedia/skiver/types"
 	"github.com/spf13/cobra"
 )
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
-	Use:   "import",
+	Use:   "import [source]",
 	Short: "Import from local i18n-file",
+	Args:  cobra.MaximumNArgs(1),
+	Long: `Import translations from local files.
+
+The source may be a single file, a directory or a glob, given as an argument or
+with --source:
+
+  skiver import en.json --locale en
+
+To import many locales and categories at once, use a pattern to extract them from
+the path of each file:
+
+  skiver import locales --pattern 'locales/{{.Locale}}/{{.Category}}.json'
+
+The translations of each file are imported beneath its category, except for the
+default i18next-namespace 'translation'. If the source is omitted, the files are
+found by the pattern.
+
+Every file is read and converted before anything is uploaded.
+
+With --interactive, the changes of a dry-run are reviewed one at a time, and only
+the accepted changes are imported. Use --save-plan to save the accepted changes
+instead, and import them later with --plan:
+
+  skiver import locales --pattern 'locales/{{.Locale}}/{{.Category}}.json' --interactive --save-plan plan.yaml
+  skiver import --plan plan.yaml
+
+By default, the imported values replace the values on the server. With --on-conflict,
+the values are first compared with the current export of the project:
+
+  overwrite   Import every value
+  skip        Keep the values on the server which differ, but import new translations and missing values
+  fail        Abort, listing every differing value, before anything is uploaded
+  only-empty  Only fill in missing values of translations already on the server`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if CLI.Import.Source == "" {
-			l.Fatal().Msg("Source is required")
+		if len(args) > 0 {
+			if cmd.Flags().Changed("source") && args[0] != CLI.Import.Source {
+				fatalCode(ExitUsage, nil).Str("source", CLI.Import.Source).Str("arg", args[0]).Msg("The source was given both as an argument and with --source")
+			}
+			CLI.Import.Source = args[0]
 		}
-		api := requireApi(true)
-		l.Debug().Str("path", CLI.Import.Source).Msg("importing")
-		source, exists := getFile(CLI.Import.Source)
-		if !exists {
-			l.Fatal().Str("path", CLI.Import.Source).Msg("File not found")
+		switch CLI.Import.Output {
+		case "", "text", "json", "yaml":
+		default:
+			fatalCode(ExitUsage, nil).Str("output", CLI.Import.Output).Msg("Invalid output. Valid outputs are: text, json, yaml")
 		}
-		_, result, err := api.Import(CLI.Project, "i18n", CLI.Locale, source, CLI.Import.DryRun)
-		if err != nil {
-			l.Fatal().Err(err).Msg("Failed to import")
+		if !validConflictPolicy(CLI.Import.OnConflict) {
+			fatalCode(ExitUsage, nil).Str("on-conflict", CLI.Import.OnConflict).Msgf("Invalid conflict-policy. Valid policies are: %s", strings.Join(conflictPolicies, ", "))
+		}
+		var files []importFile
+		if CLI.Import.Plan != "" {
+			plan, err := loadImportPlan(CLI.Import.Plan)
+			if err != nil {
+				fatalCode(ExitUsage, err).Str("path", CLI.Import.Plan).Msg("Failed to load import-plan")
+			}
+			if plan.Project != "" && plan.Project != CLI.Project {
+				fatalCode(ExitUsage, nil).Str("plan", plan.Project).Str("project", CLI.Project).Msg("The import-plan was created for another project")
+			}
+			files, err = plan.importFiles(CLI.Import.Plan)
+			if err != nil {
+				fatalCode(ExitUsage, err).Str("path", CLI.Import.Plan).Msg("Invalid import-plan")
+			}
+		} else {
+			files = readImportFiles()
+		}
+		if CLI.Import.Interactive && !isatty.IsTerminal(os.Stdin.Fd()) {
+			fatalCode(ExitUsage, nil).Msg("Interactive import requires stdin to be a terminal")
+		}
+		api := requireApi(cmd.Context(), true)
+		// The export is only needed to apply the conflict-policy
+		var ep *types.ExtendedProject
+		if CLI.Import.OnConflict != conflictOverwrite {
+			export, err := api.ExportProject(cmd.Context(), CLI.Project, "")
+			if err != nil {
+				fatalExportError(cmd.Context(), l, *api, CLI.Project, err, "Failed to export the project for comparison")
+			}
+			ep = &export
+			files, err = filterByConflictPolicy(export, CLI.Import.OnConflict, files)
+			if err != nil {
+				l.Fatal().Err(err).Str("policy", CLI.Import.OnConflict).Msg("Nothing was imported")
+			}
 		}
 
-		fmt.Printf("This would create %d translations, and %d updates\n", len(result.Diff.Creations), len(result.Diff.Updates))
-		fmt.Println(result)
-		l.Info().Msg("Successful import")
+		if CLI.Import.Interactive || CLI.Import.SavePlan != "" {
+			dryRun := importAll(cmd.Context(), api, ep, files, true)
+			var accepted acceptedChanges
+			if CLI.Import.Interactive {
+				var ok bool
+				var err error
+				accepted, ok, err = reviewImport(dryRun)
+				if err != nil {
+					l.Fatal().Err(err).Msg("Failed to read answer")
+				}
+				if !ok {
+					l.Info().Msg("Aborted, nothing was imported")
+					return
+				}
+			} else {
+				accepted = dryRun.acceptAll()
+			}
+			plan, err := buildImportPlan(CLI.Project, files, accepted)
+			if err != nil {
+				l.Fatal().Err(err).Msg("Failed to build the import-plan")
+			}
+			if plan.size() == 0 {
+				l.Info().Msg("No changes to import")
+				return
+			}
+			if CLI.Import.SavePlan != "" {
+				if err := saveImportPlan(CLI.Import.SavePlan, plan); err != nil {
+					l.Fatal().Err(err).Str("path", CLI.Import.SavePlan).Msg("Failed to save import-plan")
+				}
+				l.Info().Str("path", CLI.Import.SavePlan).Int("changes", plan.size()).Msg("Saved import-plan. Import it with --plan")
+				return
+			}
+			files, err = plan.importFiles("")
+			if err != nil {
+				l.Fatal().Err(err).Msg("Invalid import-plan")
+			}
+		}
+
+		report := importAll(cmd.Context(), api, ep, files, CLI.Import.DryRun)
+		switch CLI.Import.Output {
+		case "json":
+			marshalout(report, formatJson)
+		case "yaml":
+			marshalout(report, formatYaml)
+		default:
+			out, err := PrettyPrinttFile(".diff", report.String())
+			if err != nil {
+				l.Warn().Err(err).Msg("error during pretty-printing")
+			}
+			write(out)
+		}
+		if !CLI.Import.DryRun {
+			l.Info().Int("files", len(files)).Msg("Successful import")
+		}
 	},
 }
 
+// readImportFiles finds, reads and converts the source-files. Any error is fatal, so that
+// invalid input is rejected before anything is uploaded.
+func readImportFiles() []importFile {
+	if CLI.Import.Source == "" && CLI.Import.Pattern == "" {
+		fatalCode(ExitUsage, nil).Msg("Source, pattern or plan is required")
+	}
+	files, err := resolveImportFiles(CLI.Import.Source, CLI.Import.Pattern, CLI.Locale)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Failed to find files to import")
+	}
+	for i, f := range files {
+		l.Debug().Str("path", f.Path).Str("locale", f.Locale).Str("category", f.Category).Msg("importing")
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			l.Fatal().Err(err).Str("path", f.Path).Msg("Failed to read file")
+		}
+		format := CLI.Import.Format
+		if format == "" {
+			format, err = detectImportFormat(f.Path, content)
+			if err != nil {
+				l.Fatal().Err(err).Msg("Unrecognized source-file")
+			}
+			l.Debug().Str("path", f.Path).Str("format", format).Msg("Detected format of source-file")
+		}
+		converted, err := convertForImport(format, f.Category, content)
+		if err != nil {
+			l.Fatal().Err(err).Str("path", f.Path).Str("format", format).Msg("Failed to convert the source-file")
+		}
+		files[i].Format = format
+		files[i].Content = converted
+	}
+	return files
+}
+
+// importAll imports every file, and returns the combined report. The changes of each file are
+// taken from the diff of the import-result. If the export is given, the changes are compared with
+// it, and any disagreement is warned about.
+func importAll(ctx context.Context, api *Api, ep *types.ExtendedProject, files []importFile, dryRun bool) importReport {
+	report := importReport{DryRun: dryRun}
+	for i, f := range files {
+		if f.Content == nil {
+			// Nothing left to import after applying the conflict-policy
+			r, _ := newImportFileReport(f, handlers.ImportResult{}, nil)
+			report.add(r)
+			continue
+		}
+		_, result, err := api.Import(ctx, CLI.Project, "i18n", f.Locale, bytes.NewReader(f.Content), dryRun)
+		if err != nil {
+			fatal(err).
+				Str("path", f.Path).
+				Int("imported", i).
+				Int("remaining", len(files)-i).
+				Msg("Failed to import")
+		}
+		r, err := newImportFileReport(f, result, ep)
+		if err != nil {
+			l.Fatal().Err(err).Str("path", f.Path).Msg("Failed to read the changes of the import")
+		}
+		if ep != nil {
+			onlyServer, onlyExport, err := compareWithExport(*ep, f, r)
+			if err != nil {
+				l.Fatal().Err(err).Str("path", f.Path).Msg("Failed to compare the file with the project")
+			}
+			if len(onlyServer) > 0 || len(onlyExport) > 0 {
+				l.Warn().
+					Str("path", f.Path).
+					Strs("only-reported-by-server", onlyServer).
+					Strs("only-found-in-export", onlyExport).
+					Msg("The changes reported by the server differ from the changes found by comparing with the export")
+			}
+		}
+		report.add(r)
+	}
+	return report
+}
+
 func init() {
 	rootCmd.AddCommand(importCmd)
 	s := reflect.TypeOf(CLI.Import)
-	for _, v := range []string{"Source", "DryRun"} {
+	for _, v := range []string{"Source", "DryRun", "Pattern", "Format", "Output", "Interactive", "Plan", "SavePlan", "OnConflict"} {
 		mustSetVar(s, v, importCmd, "import.")
 	}
 }
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// importFile is a single source-file to import, with the locale and category extracted from the path
+type importFile struct {
+	Path     string
+	Locale   string
+	Category string
+	Format   string
+	// Content is the converted content, as accepted by the server
+	Content []byte
+	// Skipped are the values removed by the conflict-policy
+	Skipped []importChange
+}
+
+var importPatternPlaceholder = regexp.MustCompile(`{{\s*\.(Locale|Category)\s*}}`)
+
+// compileImportPattern converts a pattern like 'locales/{{.Locale}}/{{.Category}}.json' into a regex
+// matching the end of a path, and a glob for finding the files.
+func compileImportPattern(pattern string) (*regexp.Regexp, string, error) {
+	pattern = filepath.ToSlash(pattern)
+	var re, glob strings.Builder
+	re.WriteString(`(?:^|/)`)
+	seen := map[string]bool{}
+	last := 0
+	for _, m := range importPatternPlaceholder.FindAllStringSubmatchIndex(pattern, -1) {
+		name := pattern[m[2]:m[3]]
+		if seen[name] {
+			return nil, "", fmt.Errorf("the pattern '%s' contains {{.%s}} multiple times", pattern, name)
+		}
+		seen[name] = true
+		re.WriteString(regexp.QuoteMeta(pattern[last:m[0]]))
+		fmt.Fprintf(&re, `(?P<%s>[^/]+?)`, name)
+		glob.WriteString(pattern[last:m[0]])
+		glob.WriteString("*")
+		last = m[1]
+	}
+	if !seen["Locale"] {
+		return nil, "", fmt.Errorf("the pattern '%s' must contain {{.Locale}}", pattern)
+	}
+	re.WriteString(regexp.QuoteMeta(pattern[last:]))
+	re.WriteString("$")
+	glob.WriteString(pattern[last:])
+	r, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, "", err
+	}
+	return r, filepath.FromSlash(glob.String()), nil
+}
+
+// findImportFiles returns the files matched by the source, which may be a file, a directory or a glob.
+// Without a source, the files are found by the glob derived from the pattern.
+func findImportFiles(source, patternGlob string) ([]string, error) {
+	if source == "" {
+		return filepath.Glob(patternGlob)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// Not a file, so it may be a glob
+		return filepath.Glob(source)
+	}
+	if !info.IsDir() {
+		return []string{source}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != source && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isImportExtension(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func isImportExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range importFormats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveImportFiles finds the files to import, and extracts the locale and category of each.
+// Without a pattern, every file is imported into the fallback-locale.
+func resolveImportFiles(source, pattern, fallbackLocale string) ([]importFile, error) {
+	var re *regexp.Regexp
+	glob := ""
+	if pattern != "" {
+		var err error
+		re, glob, err = compileImportPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	paths, err := findImportFiles(source, glob)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		if source == "" {
+			return nil, fmt.Errorf("no files matched the pattern '%s'", pattern)
+		}
+		return nil, fmt.Errorf("no files found at '%s'", source)
+	}
+	sort.Strings(paths)
+	var files []importFile
+	for _, p := range paths {
+		f := importFile{Path: p, Locale: fallbackLocale}
+		if re != nil {
+			m := re.FindStringSubmatch(filepath.ToSlash(p))
+			if m == nil {
+				l.Debug().Str("path", p).Str("pattern", pattern).Msg("Skipping file not matching the pattern")
+				continue
+			}
+			f.Locale = m[re.SubexpIndex("Locale")]
+			if i := re.SubexpIndex("Category"); i >= 0 {
+				f.Category = m[i]
+			}
+		}
+		if f.Locale == "" {
+			return nil, fmt.Errorf("no locale for '%s'. Set the locale, or use a pattern containing {{.Locale}}", p)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("none of the %d files found matched the pattern '%s'", len(paths), pattern)
+	}
+	return files, nil
+}
+
+// prefixCategory nests the converted translations beneath the category. Like with generate --split,
+// the default namespace of i18next is imported without a category.
+func prefixCategory(category string, m map[string]interface{}) map[string]interface{} {
+	if category == "" || category == rootNamespace {
+		return m
+	}
+	parts := strings.Split(category, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		m = map[string]interface{}{parts[i]: m}
+	}
+	return m
+}
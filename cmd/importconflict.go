@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+)
+
+// Policies for values within the import that differ from the values on the server
+const (
+	// conflictOverwrite imports every value, replacing the values on the server
+	conflictOverwrite = "overwrite"
+	// conflictSkip keeps the values on the server, but imports new translations and missing values
+	conflictSkip = "skip"
+	// conflictFail aborts the import, before anything is uploaded
+	conflictFail = "fail"
+	// conflictOnlyEmpty only fills in missing values of translations already on the server
+	conflictOnlyEmpty = "only-empty"
+)
+
+var conflictPolicies = []string{conflictOverwrite, conflictSkip, conflictFail, conflictOnlyEmpty}
+
+func validConflictPolicy(policy string) bool {
+	for _, p := range conflictPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// serverValues returns the values of the locale, with contexts as '<key>_<context>', like the
+// keys of the converted import-files. This is like BuildTranslationKeyFromApi, but it is not an
+// error for the locale to be empty.
+func serverValues(ep types.ExtendedProject, locale string) (map[string]string, error) {
+	m, err := FlattenExtendedProject(ep, []string{locale})
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	for key, byLocale := range m {
+		for loc, v := range byLocale {
+			switch {
+			case loc == locale:
+				values[key] = v
+			case strings.HasPrefix(loc, locale+"_"):
+				values[key+"_"+strings.TrimPrefix(loc, locale+"_")] = v
+			}
+		}
+	}
+	return values, nil
+}
+
+// filterByConflictPolicy compares the files with the export of the project, and removes the
+// values that do not satisfy the policy. Files without any remaining values get an empty content.
+// With the policy 'fail', an error listing every conflict is returned instead.
+func filterByConflictPolicy(ep types.ExtendedProject, policy string, files []importFile) ([]importFile, error) {
+	if policy == conflictOverwrite {
+		return files, nil
+	}
+	// keys that exist on the server, in any locale
+	existing := projectKeys(ep)
+	var conflicts []string
+	var kept []importFile
+	for _, f := range files {
+		server, err := serverValues(ep, f.Locale)
+		if err != nil {
+			return nil, err
+		}
+		local, err := importFileValues(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range utils.SortedMapKeys(local) {
+			current, has := server[k]
+			if !has || current == "" {
+				if policy == conflictOnlyEmpty && !keyExists(existing, k) {
+					f.Skipped = append(f.Skipped, importChange{Key: k, Locale: f.Locale, New: local[k]})
+					delete(local, k)
+				}
+				continue
+			}
+			if current == local[k] {
+				continue
+			}
+			change := importChange{Key: k, Locale: f.Locale, Old: current, New: local[k]}
+			if policy == conflictFail {
+				conflicts = append(conflicts, fmt.Sprintf("%s [%s] in %s", k, f.Locale, f.Path))
+				continue
+			}
+			f.Skipped = append(f.Skipped, change)
+			delete(local, k)
+		}
+		if len(f.Skipped) > 0 {
+			l.Debug().Str("path", f.Path).Int("skipped", len(f.Skipped)).Str("policy", policy).Msg("Skipped values by the conflict-policy")
+		}
+		if len(local) == 0 {
+			// The file is kept, so that the skipped values are reported
+			l.Info().Str("path", f.Path).Str("locale", f.Locale).Msg("Nothing to import after applying the conflict-policy")
+			f.Content = nil
+			kept = append(kept, f)
+			continue
+		}
+		nested, err := nestKeys(local)
+		if err != nil {
+			return nil, err
+		}
+		if f.Content, err = marshalJSON(nested); err != nil {
+			return nil, err
+		}
+		kept = append(kept, f)
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%d values differ from the server:\n  %s", len(conflicts), strings.Join(conflicts, "\n  "))
+	}
+	return kept, nil
+}
+
+// keyExists reports whether the key, or the key without the context, like 'greeting' for 'greeting_male', exists
+func keyExists(existing map[string]struct{}, key string) bool {
+	if _, ok := existing[key]; ok {
+		return true
+	}
+	if i := strings.LastIndex(key, "_"); i > 0 {
+		_, ok := existing[key[:i]]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// importFormat converts a source-file into the nested i18next-structure accepted by the server.
+type importFormat struct {
+	Description string
+	Extensions  []string
+	Convert     func(content []byte) (map[string]interface{}, error)
+}
+
+// importFormats is the registry of formats that can be imported. Only i18next is
+// understood by the server; the others are converted locally.
+var importFormats = map[string]importFormat{
+	"i18next": {
+		Description: "i18next-compatible nested json",
+		Extensions:  []string{".json"},
+		Convert:     convertJSONImport,
+	},
+	"json-flat": {
+		Description: "Flat json, with dot-delimited keys",
+		Extensions:  []string{".json"},
+		Convert:     convertJSONImport,
+	},
+	"yaml": {
+		Description: "Nested or flat yaml",
+		Extensions:  []string{".yaml", ".yml"},
+		Convert:     convertYAMLImport,
+	},
+	"gettext": {
+		Description: "Gettext po-files, with the msgid as the key. Dots within the msgid are escaped as %2E",
+		Extensions:  []string{".po", ".pot"},
+		Convert:     convertGettextImport,
+	},
+}
+
+func importFormatNames() []string {
+	names := make([]string, 0, len(importFormats))
+	for k := range importFormats {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectImportFormat detects the format of the source-file, from the extension, and then the content.
+func detectImportFormat(path string, content []byte) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	trimmed := bytes.TrimSpace(content)
+	switch {
+	case ext == ".json", ext == "" && bytes.HasPrefix(trimmed, []byte("{")):
+		var m map[string]interface{}
+		if err := json.Unmarshal(content, &m); err != nil {
+			return "", fmt.Errorf("%s does not contain a json-object: %w", path, err)
+		}
+		if isFlatMap(m) {
+			return "json-flat", nil
+		}
+		return "i18next", nil
+	case ext == ".yaml", ext == ".yml":
+		return "yaml", nil
+	case ext == ".po", ext == ".pot", bytes.Contains(content, []byte("\nmsgid \"")), bytes.HasPrefix(trimmed, []byte("msgid \"")):
+		return "gettext", nil
+	}
+	// Finally, attempt yaml, which json is also a subset of.
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(content, &m); err == nil && len(m) > 0 {
+		return "yaml", nil
+	}
+	return "", fmt.Errorf("could not detect the format of %s. Use --format with one of: %s", path, strings.Join(importFormatNames(), ", "))
+}
+
+// isFlatMap returns true if every value is a string, and at least one key is dot-delimited
+func isFlatMap(m map[string]interface{}) bool {
+	dotted := false
+	for k, v := range m {
+		if _, ok := v.(string); !ok {
+			return false
+		}
+		if strings.Contains(k, ".") {
+			dotted = true
+		}
+	}
+	return dotted
+}
+
+// convertForImport converts the content into the i18next-json accepted by the server,
+// nested beneath the category, if set.
+func convertForImport(format, category string, content []byte) ([]byte, error) {
+	f, ok := importFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import-format '%s'. Valid formats are: %s", format, strings.Join(importFormatNames(), ", "))
+	}
+	m, err := f.Convert(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("found no translations")
+	}
+	return marshalJSON(prefixCategory(category, m))
+}
+
+// convertJSONImport validates the json, and nests any dot-delimited keys.
+func convertJSONImport(content []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("invalid json, expected an object of translations: %w", err)
+	}
+	flat := map[string]string{}
+	if err := flattenImport("", m, flat); err != nil {
+		return nil, err
+	}
+	return nestKeys(flat)
+}
+
+// flattenImport flattens nested maps into dot-delimited keys, and rejects values that are not translations.
+func flattenImport(prefix string, m map[string]interface{}, flat map[string]string) error {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch child := v.(type) {
+		case string:
+			if _, ok := flat[key]; ok {
+				return fmt.Errorf("the key '%s' is defined multiple times", key)
+			}
+			flat[key] = child
+		case map[string]interface{}:
+			if err := flattenImport(key, child, flat); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("the value of '%s' is a %T, but only strings and objects are supported", key, v)
+		}
+	}
+	return nil
+}
+
+// convertYAMLImport decodes the yaml with every scalar kept as a string, so that values
+// like Yes, No and 5 are not turned into booleans and numbers, as they would with YAML 1.1.
+func convertYAMLImport(content []byte) (map[string]interface{}, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+	flat := map[string]string{}
+	if len(doc.Content) == 0 {
+		return nestKeys(flat)
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("invalid yaml, expected a mapping of translations")
+	}
+	if err := flattenYAMLImport("", root, flat); err != nil {
+		return nil, err
+	}
+	return nestKeys(flat)
+}
+
+// flattenYAMLImport is like flattenImport, but for yaml-nodes.
+func flattenYAMLImport(prefix string, n *yamlv3.Node, flat map[string]string) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		v := n.Content[i+1]
+		if v.Kind == yamlv3.AliasNode {
+			v = v.Alias
+		}
+		switch {
+		case v.Kind == yamlv3.ScalarNode && v.Tag != "!!null":
+			if _, ok := flat[key]; ok {
+				return fmt.Errorf("the key '%s' is defined multiple times", key)
+			}
+			flat[key] = v.Value
+		case v.Kind == yamlv3.MappingNode:
+			if err := flattenYAMLImport(key, v, flat); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("the value of '%s' on line %d is not a translation or an object", key, v.Line)
+		}
+	}
+	return nil
+}
+
+// gettextKeyEscaper escapes the key-separator within msgids, which are usually sentences.
+var gettextKeyEscaper = strings.NewReplacer("%", "%25", ".", "%2E")
+
+// convertGettextImport converts a po-file. msgctxt is used as the i18next-context, and plurals
+// are imported with the suffixes _one and _other (and _2, _3... for additional forms).
+// The msgid is used as the key, with dots escaped, so that it is not split into categories.
+func convertGettextImport(content []byte) (map[string]interface{}, error) {
+	flat := map[string]string{}
+	type entry struct {
+		ctxt, id, plural string
+		str              map[int]*string
+	}
+	var cur entry
+	// field is the field continuation-lines are appended to
+	var field *string
+	lineNo := 0
+	flush := func() error {
+		defer func() {
+			cur = entry{}
+			field = nil
+		}()
+		// The header is stored with an empty msgid
+		if cur.id == "" {
+			return nil
+		}
+		key := gettextKeyEscaper.Replace(cur.id)
+		if cur.ctxt != "" {
+			key += "_" + gettextKeyEscaper.Replace(cur.ctxt)
+		}
+		for n, s := range cur.str {
+			if *s == "" {
+				continue
+			}
+			k := key
+			if cur.plural != "" {
+				switch n {
+				case 0:
+					k += "_one"
+				case 1:
+					k += "_other"
+				default:
+					k += "_" + strconv.Itoa(n)
+				}
+			}
+			if _, ok := flat[k]; ok {
+				return fmt.Errorf("the key '%s' is defined multiple times", k)
+			}
+			flat[k] = *s
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, rest := line, ""
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			keyword, rest = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if strings.HasPrefix(line, `"`) {
+			keyword, rest = "", line
+		}
+		s, err := strconv.Unquote(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string on line %d: %s", lineNo, line)
+		}
+		switch {
+		case keyword == "":
+			if field == nil {
+				return nil, fmt.Errorf("unexpected string on line %d: %s", lineNo, line)
+			}
+			*field += s
+			continue
+		case keyword == "msgctxt":
+			// A new entry may start without a blank line
+			if cur.str != nil {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			cur.ctxt = s
+			field = &cur.ctxt
+		case keyword == "msgid":
+			if cur.str != nil {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			cur.id = s
+			field = &cur.id
+		case keyword == "msgid_plural":
+			cur.plural = s
+			field = &cur.plural
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			n := 0
+			if keyword != "msgstr" {
+				n, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if err != nil {
+					return nil, fmt.Errorf("invalid plural-index on line %d: %s", lineNo, line)
+				}
+			}
+			if cur.str == nil {
+				cur.str = map[int]*string{}
+			}
+			cur.str[n] = &s
+			field = &s
+		default:
+			return nil, fmt.Errorf("unknown keyword '%s' on line %d", keyword, lineNo)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return nestKeys(flat)
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertGettextImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "Header is skipped",
+			content: `msgid ""
+msgstr "Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "Hello"
+msgstr "Hei"
+`,
+			want: map[string]interface{}{"Hello": "Hei"},
+		},
+		{
+			name: "Dots within sentences are escaped",
+			content: `msgid "Welcome. Please log in."
+msgstr "Velkommen. Logg inn."
+`,
+			want: map[string]interface{}{"Welcome%2E Please log in%2E": "Velkommen. Logg inn."},
+		},
+		{
+			name: "Percent-signs are escaped",
+			content: `msgid "100% done."
+msgstr "100 % ferdig."
+`,
+			want: map[string]interface{}{"100%25 done%2E": "100 % ferdig."},
+		},
+		{
+			name: "Continuation-lines",
+			content: `msgid ""
+"Multi"
+"line"
+msgstr ""
+"Fler"
+"linjer"
+`,
+			want: map[string]interface{}{"Multiline": "Flerlinjer"},
+		},
+		{
+			name: "Context",
+			content: `msgctxt "male"
+msgid "Friend"
+msgstr "Venn"
+msgctxt "female"
+msgid "Friend"
+msgstr "Venninne"
+`,
+			want: map[string]interface{}{"Friend_male": "Venn", "Friend_female": "Venninne"},
+		},
+		{
+			name: "Plurals",
+			content: `msgid "apple"
+msgid_plural "apples"
+msgstr[0] "eple"
+msgstr[1] "epler"
+msgstr[2] "eplene"
+`,
+			want: map[string]interface{}{"apple_one": "eple", "apple_other": "epler", "apple_2": "eplene"},
+		},
+		{
+			name: "Comments and untranslated entries are skipped",
+			content: `# translator-comment
+#: src/main.go:12
+msgid "Yes"
+msgstr "Ja"
+
+msgid "No"
+msgstr ""
+`,
+			want: map[string]interface{}{"Yes": "Ja"},
+		},
+		{
+			name: "Escape-sequences",
+			content: `msgid "Say \"hi\""
+msgstr "Si \"hei\"\n"
+`,
+			want: map[string]interface{}{`Say "hi"`: "Si \"hei\"\n"},
+		},
+		{
+			name: "Duplicate msgid",
+			content: `msgid "Hello"
+msgstr "Hei"
+
+msgid "Hello"
+msgstr "Hallo"
+`,
+			wantErr: true,
+		},
+		{
+			name:    "Unknown keyword",
+			content: `msgfoo "Hello"`,
+			wantErr: true,
+		},
+		{
+			name:    "Unterminated string",
+			content: `msgid "Hello`,
+			wantErr: true,
+		},
+		{
+			name: "Continuation without a keyword",
+			content: `"orphan"
+`,
+			wantErr: true,
+		},
+		{
+			name: "Invalid plural-index",
+			content: `msgid "apple"
+msgid_plural "apples"
+msgstr[x] "eple"
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertGettextImport([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertGettextImport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertGettextImport() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertYAMLImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "Scalars are kept as strings",
+			content: `confirm: Yes
+cancel: No
+count: 5
+ratio: 1.50
+`,
+			want: map[string]interface{}{"confirm": "Yes", "cancel": "No", "count": "5", "ratio": "1.50"},
+		},
+		{
+			name: "Nested and dotted keys",
+			content: `general:
+  ok: OK
+general.cancel: Avbryt
+`,
+			want: map[string]interface{}{"general": map[string]interface{}{"ok": "OK", "cancel": "Avbryt"}},
+		},
+		{
+			name:    "Lists are rejected",
+			content: "items:\n  - a\n  - b\n",
+			wantErr: true,
+		},
+		{
+			name:    "Empty values are rejected",
+			content: "empty:\n",
+			wantErr: true,
+		},
+		{
+			name:    "The root must be a mapping",
+			content: "- a\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertYAMLImport([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertYAMLImport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertYAMLImport() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
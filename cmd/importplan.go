@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/runar-rkmedia/skiver/utils"
+)
+
+// importPlan is the set of changes approved during an interactive import. It can be saved,
+// and imported later with --plan.
+type importPlan struct {
+	Project   string    `json:"project"`
+	CreatedAt time.Time `json:"created_at"`
+	// Locales maps the locale to the approved values, by their full dot-delimited key
+	Locales map[string]map[string]string `json:"locales"`
+}
+
+func newImportPlan(project string) importPlan {
+	return importPlan{Project: project, CreatedAt: time.Now(), Locales: map[string]map[string]string{}}
+}
+
+func (p *importPlan) add(locale, key, value string) error {
+	if p.Locales[locale] == nil {
+		p.Locales[locale] = map[string]string{}
+	}
+	if existing, ok := p.Locales[locale][key]; ok && existing != value {
+		return fmt.Errorf("the key '%s' [%s] has different values within multiple files", key, locale)
+	}
+	p.Locales[locale][key] = value
+	return nil
+}
+
+func (p importPlan) size() int {
+	n := 0
+	for _, values := range p.Locales {
+		n += len(values)
+	}
+	return n
+}
+
+// importFiles converts the plan into a file per locale, ready for import
+func (p importPlan) importFiles(source string) ([]importFile, error) {
+	var files []importFile
+	for _, locale := range utils.SortedMapKeys(p.Locales) {
+		values := p.Locales[locale]
+		if len(values) == 0 {
+			continue
+		}
+		nested, err := nestKeys(values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keys for locale %s: %w", locale, err)
+		}
+		b, err := marshalJSON(nested)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, importFile{Path: source, Locale: locale, Format: "plan", Content: b})
+	}
+	return files, nil
+}
+
+// saveImportPlan writes the plan as yaml, or json if the extension is .json
+func saveImportPlan(path string, p importPlan) error {
+	mode := formatYaml
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		mode = formatJson
+	}
+	return writeFileAtomic(path, marshal(p, mode), 0o644)
+}
+
+func loadImportPlan(path string) (importPlan, error) {
+	var p importPlan
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	// yaml is a superset of json
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("invalid import-plan: %w", err)
+	}
+	if len(p.Locales) == 0 {
+		return p, fmt.Errorf("the import-plan %s contains no changes", path)
+	}
+	return p, nil
+}
+
+// acceptedChanges holds the accepted keys of every file of a dry-run, in the same order as the files.
+// The keys are those of the creations and updates within the diff of the import-result.
+// The value is the edited value, or empty to import the value of the file.
+type acceptedChanges []map[string]string
+
+// acceptAll returns every change of the dry-run as accepted
+func (r importReport) acceptAll() acceptedChanges {
+	accepted := make(acceptedChanges, len(r.Files))
+	for i, f := range r.Files {
+		accepted[i] = map[string]string{}
+		for _, c := range f.Creations {
+			accepted[i][c.Key] = ""
+		}
+		for _, c := range f.Updates {
+			accepted[i][c.Key] = ""
+		}
+	}
+	return accepted
+}
+
+// buildImportPlan builds the plan from the converted content of the files, filtered by the accepted keys
+// of the dry-run. A key reported by the server which is not found within its file is an error.
+func buildImportPlan(project string, files []importFile, accepted acceptedChanges) (importPlan, error) {
+	plan := newImportPlan(project)
+	if len(accepted) != len(files) {
+		return plan, fmt.Errorf("expected the changes of %d files, but got %d", len(files), len(accepted))
+	}
+	for i, f := range files {
+		if len(accepted[i]) == 0 {
+			continue
+		}
+		values, err := importFileValues(f)
+		if err != nil {
+			return plan, err
+		}
+		for _, key := range utils.SortedMapKeys(accepted[i]) {
+			value, ok := values[key]
+			if !ok {
+				return plan, fmt.Errorf("the server reported a change of '%s' [%s], which was not found within %s", key, f.Locale, f.Path)
+			}
+			if edited := accepted[i][key]; edited != "" {
+				value = edited
+			}
+			if err := plan.add(f.Locale, key, value); err != nil {
+				return plan, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+// reviewImport lets the user accept, skip or edit every creation and update within the diff of a dry-run,
+// and returns the accepted changes.
+// If the user quits, ok is false.
+func reviewImport(report importReport) (accepted acceptedChanges, ok bool, err error) {
+	accepted = make(acceptedChanges, len(report.Files))
+	total := report.Creations + report.Updates
+	n := 0
+	acceptAll := false
+	for fi, f := range report.Files {
+		accepted[fi] = map[string]string{}
+		changes := make([]importChange, 0, len(f.Creations)+len(f.Updates))
+		changes = append(changes, f.Creations...)
+		changes = append(changes, f.Updates...)
+		for i, c := range changes {
+			n++
+			if acceptAll {
+				accepted[fi][c.Key] = ""
+				continue
+			}
+			out, _ := PrettyPrinttFile(".diff", fmt.Sprintf("@@ %d/%d %s @@\n%s", n, total, f.Path, c.diff(i < len(f.Creations))))
+			fmt.Fprint(os.Stderr, out)
+			for answered := false; !answered; {
+				fmt.Fprint(os.Stderr, "[a]ccept, [s]kip, [e]dit, accept a[l]l remaining, [q]uit: ")
+				answer, err := stdinReader.ReadString('\n')
+				if err == io.EOF && answer == "" {
+					// Treat ctrl-d like quitting
+					return accepted, false, nil
+				}
+				if err != nil && err != io.EOF {
+					return accepted, false, err
+				}
+				answered = true
+				switch strings.ToLower(strings.TrimSpace(answer)) {
+				case "a", "accept", "y", "yes":
+					accepted[fi][c.Key] = ""
+				case "s", "skip", "n", "no":
+				case "e", "edit":
+					fmt.Fprintf(os.Stderr, "New value (empty to keep %q): ", c.New)
+					value, err := readLine()
+					if err != nil {
+						return accepted, false, err
+					}
+					accepted[fi][c.Key] = value
+				case "l", "all":
+					acceptAll = true
+					accepted[fi][c.Key] = ""
+				case "q", "quit":
+					return accepted, false, nil
+				default:
+					answered = false
+				}
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	return accepted, true, nil
+}
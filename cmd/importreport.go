@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/runar-rkmedia/skiver/handlers"
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+)
+
+// importReport is the combined result of importing one or more files
+type importReport struct {
+	DryRun    bool               `json:"dry_run"`
+	Creations int                `json:"creations"`
+	Updates   int                `json:"updates"`
+	Warnings  int                `json:"warnings"`
+	Skipped   int                `json:"skipped"`
+	Files     []importFileReport `json:"files"`
+}
+
+type importFileReport struct {
+	Path      string         `json:"path"`
+	Locale    string         `json:"locale"`
+	Category  string         `json:"category,omitempty"`
+	Format    string         `json:"format"`
+	Creations []importChange `json:"creations"`
+	Updates   []importChange `json:"updates"`
+	Warnings  []string       `json:"warnings,omitempty"`
+	Skipped   []importChange `json:"skipped,omitempty"`
+}
+
+type importChange struct {
+	Key    string `json:"key"`
+	Locale string `json:"locale"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// newImportFileReport creates the report of a file from the diff and the warnings of the import-result.
+// The keys of the changed values are found within the import-result, or the export if given.
+func newImportFileReport(f importFile, result handlers.ImportResult, ep *types.ExtendedProject) (importFileReport, error) {
+	r := importFileReport{
+		Path:      f.Path,
+		Locale:    f.Locale,
+		Category:  f.Category,
+		Format:    f.Format,
+		Creations: []importChange{},
+		Updates:   []importChange{},
+		Skipped:   f.Skipped,
+	}
+	keys := map[string]string{}
+	for _, c := range result.Imp.Categories {
+		addTranslationKeys(keys, c)
+	}
+	if ep != nil {
+		addTranslationKeys(keys, ep.CategoryTree)
+	}
+	key := func(tv types.TranslationValue) (string, error) {
+		k, ok := keys[tv.TranslationID]
+		if !ok {
+			return "", fmt.Errorf("the translation '%s' of a change within %s was not found within the import-result", tv.TranslationID, f.Path)
+		}
+		return k, nil
+	}
+	for _, tv := range result.Diff.Creations {
+		k, err := key(tv)
+		if err != nil {
+			return r, err
+		}
+		r.Creations = append(r.Creations, valueChanges(k, f.Locale, types.TranslationValue{}, tv)...)
+	}
+	for _, u := range result.Diff.Updates {
+		k, err := key(u.Target)
+		if err != nil {
+			return r, err
+		}
+		r.Updates = append(r.Updates, valueChanges(k, f.Locale, u.Source, u.Target)...)
+	}
+	sortImportChanges(r.Creations)
+	sortImportChanges(r.Updates)
+	for _, w := range result.Warnings {
+		r.Warnings = append(r.Warnings, describeImportWarning(w))
+	}
+	return r, nil
+}
+
+// addTranslationKeys adds the full dot-delimited key of every translation within the category, by its id
+func addTranslationKeys(keys map[string]string, tree types.ExtendedCategory) {
+	walkCategories(tree, func(c types.ExtendedCategory) {
+		for id, t := range c.Translations {
+			if t.ID != "" {
+				id = t.ID
+			}
+			key := t.Key
+			if c.Key != "" {
+				key = c.Key + "." + t.Key
+			}
+			keys[id] = key
+		}
+	})
+}
+
+// valueChanges returns the changes from old to new of the value, and of every context, as '<key>_<context>'
+func valueChanges(key, locale string, old, new types.TranslationValue) []importChange {
+	var changes []importChange
+	if new.Value != "" && new.Value != old.Value {
+		changes = append(changes, importChange{Key: key, Locale: locale, Old: old.Value, New: new.Value})
+	}
+	for _, ctx := range utils.SortedMapKeys(new.Context) {
+		if v := new.Context[ctx]; v != old.Context[ctx] {
+			changes = append(changes, importChange{Key: key + "_" + ctx, Locale: locale, Old: old.Context[ctx], New: v})
+		}
+	}
+	return changes
+}
+
+func sortImportChanges(changes []importChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+}
+
+func (r *importReport) add(f importFileReport) {
+	r.Files = append(r.Files, f)
+	r.Creations += len(f.Creations)
+	r.Updates += len(f.Updates)
+	r.Warnings += len(f.Warnings)
+	r.Skipped += len(f.Skipped)
+}
+
+// importFileValues returns the converted values of the file, by their full dot-delimited key
+func importFileValues(f importFile) (map[string]string, error) {
+	var j map[string]interface{}
+	if err := json.Unmarshal(f.Content, &j); err != nil {
+		return nil, fmt.Errorf("failed to read the converted content of %s: %w", f.Path, err)
+	}
+	values := map[string]string{}
+	for k, v := range Flatten(j) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("the value of '%s' within the converted content of %s is a %T", k, f.Path, v)
+		}
+		values[k] = s
+	}
+	return values, nil
+}
+
+// diffImportFile compares the converted content of the file with the values within the export,
+// and returns the values that the import is expected to create and update, sorted by key.
+func diffImportFile(ep types.ExtendedProject, f importFile) (creations, updates []importChange, err error) {
+	if f.Content == nil {
+		return nil, nil, nil
+	}
+	local, err := importFileValues(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := serverValues(ep, f.Locale)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range utils.SortedMapKeys(local) {
+		current := server[k]
+		switch {
+		case current == "":
+			creations = append(creations, importChange{Key: k, Locale: f.Locale, New: local[k]})
+		case current != local[k]:
+			updates = append(updates, importChange{Key: k, Locale: f.Locale, Old: current, New: local[k]})
+		}
+	}
+	return creations, updates, nil
+}
+
+// compareWithExport compares the changes reported by the server with the changes found by diffImportFile,
+// and returns the keys that are only found by either.
+func compareWithExport(ep types.ExtendedProject, f importFile, r importFileReport) (onlyServer, onlyExport []string, err error) {
+	creations, updates, err := diffImportFile(ep, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	server := map[string]struct{}{}
+	for _, c := range append(append([]importChange{}, r.Creations...), r.Updates...) {
+		server[c.Key] = struct{}{}
+	}
+	export := map[string]struct{}{}
+	for _, c := range append(creations, updates...) {
+		export[c.Key] = struct{}{}
+		if _, ok := server[c.Key]; !ok {
+			onlyExport = append(onlyExport, c.Key)
+		}
+	}
+	for _, k := range utils.SortedMapKeys(server) {
+		if _, ok := export[k]; !ok {
+			onlyServer = append(onlyServer, k)
+		}
+	}
+	sort.Strings(onlyExport)
+	return onlyServer, onlyExport, nil
+}
+
+func describeImportWarning(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// diff renders the change as lines of a diff
+func (c importChange) diff(created bool) string {
+	if created {
+		return fmt.Sprintf("+ %s [%s]\n+     %q\n", c.Key, c.Locale, c.New)
+	}
+	return fmt.Sprintf("  %s [%s]\n-     %q\n+     %q\n", c.Key, c.Locale, c.Old, c.New)
+}
+
+// String renders the report as a diff, grouped by file, with the warnings listed separately.
+// The output is highlighted as a diff by the pretty-printer.
+func (r importReport) String() string {
+	sb := strings.Builder{}
+	for _, f := range r.Files {
+		if len(f.Creations) == 0 && len(f.Updates) == 0 {
+			continue
+		}
+		heading := f.Locale
+		if f.Category != "" {
+			heading += ", " + f.Category
+		}
+		fmt.Fprintf(&sb, "@@ %s (%s) @@\n", f.Path, heading)
+		for _, c := range f.Creations {
+			sb.WriteString(c.diff(true))
+		}
+		for _, c := range f.Updates {
+			sb.WriteString(c.diff(false))
+		}
+		sb.WriteString("\n")
+	}
+	if r.Skipped > 0 {
+		sb.WriteString("Skipped by the conflict-policy:\n")
+		for _, f := range r.Files {
+			for _, c := range f.Skipped {
+				if c.Old == "" {
+					fmt.Fprintf(&sb, "  %s [%s]: %q\n", c.Key, c.Locale, c.New)
+					continue
+				}
+				fmt.Fprintf(&sb, "  %s [%s]: kept %q instead of %q\n", c.Key, c.Locale, c.Old, c.New)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if r.Warnings > 0 {
+		sb.WriteString("Warnings:\n")
+		for _, f := range r.Files {
+			for _, w := range f.Warnings {
+				fmt.Fprintf(&sb, "  %s: %s\n", f.Path, w)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	verb := "Created"
+	if r.DryRun {
+		verb = "This would create"
+	}
+	fmt.Fprintf(&sb, "%s %d translations, and %d updates from %d files", verb, r.Creations, r.Updates, len(r.Files))
+	if r.Skipped > 0 {
+		fmt.Fprintf(&sb, ", skipping %d values", r.Skipped)
+	}
+	if r.Warnings > 0 {
+		fmt.Fprintf(&sb, ", with %d warnings", r.Warnings)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
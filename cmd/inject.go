@@ -56,8 +56,8 @@ var injectCmd = &cobra.Command{
 		if _, err := os.Stat(CLI.Inject.Dir); err != nil {
 			l.Fatal().Err(err).Msg("Error locating Inject.Dir")
 		}
-		api := requireApi(false)
-		m := BuildTranslationKeyFromApi(*api, l, CLI.Project, CLI.Locale)
+		api := requireApi(cmd.Context(), false)
+		m := BuildTranslationKeyFromApi(cmd.Context(), *api, l, CLI.Project, CLI.Locale)
 		sorted := utils.SortedMapKeys(m)
 		filter := []string{"ts", "tsx"}
 		regex := buildTranslationKeyRegexFromMap(sorted)
@@ -82,9 +82,9 @@ var injectCmd = &cobra.Command{
 
 		CLI.IgnoreFilter = append(CLI.IgnoreFilter, importPath)
 		in := NewInjector(l, CLI.Inject.Dir, CLI.Inject.DryRun, CLI.Inject.OnReplace, CLI.IgnoreFilter, filter, regex, replacementFunc, traverserFunc)
-		err := in.Inject()
+		err := in.Inject(cmd.Context())
 		if err != nil {
-			l.Fatal().Err(err).Msg("Failed to inject")
+			fatal(err).Msg("Failed to inject")
 		}
 		l.Info().
 			Str("dir", CLI.Inject.Dir).
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
@@ -13,7 +14,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/dustin/go-humanize"
 	"github.com/runar-rkmedia/go-common/logger"
 )
 
@@ -87,12 +87,15 @@ func NewInjector(
 	return in
 }
 
-func (in Injecter) Inject() error {
+// Inject visits all matching files within Dir concurrently.
+// If the context is cancelled, or a file fails, no new files are visited, but
+// files already being written are completed.
+func (in Injecter) Inject(ctx context.Context) error {
 	in.l.Debug().
 		Str("dir", in.Dir).
 		Msg("Started injection in path")
-	paths := map[string]fs.FileInfo{}
-	wg := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	type s = struct {
 		FilePath string
@@ -100,50 +103,58 @@ func (in Injecter) Inject() error {
 	}
 	concurrency := runtime.NumCPU()
 	ch := make(chan s, concurrency)
-	writtenCh := make(chan st)
-
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
 	var written _written
+	var firstErr error
+	count := 0
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
 
 	start := time.Now()
 
 	for i := 0; i < concurrency; i++ {
-		go func() error {
-			for {
-				select {
-				case ss := <-ch:
-					sst := st{FilePath: ss.FilePath, Start: time.Now()}
-					changed, err := in.VisitFile(ss.FilePath, ss.Info)
-					sst.Duration = time.Now().Sub(sst.Start)
-					if err != nil {
-						in.l.Fatal().Err(err).Str("path", ss.FilePath).Msg("Failed replacement in file")
-					}
-					if in.l.HasDebug() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ss := range ch {
+				if ctx.Err() != nil {
+					continue
+				}
+				sst := st{FilePath: ss.FilePath, Start: time.Now()}
+				changed, err := in.VisitFile(ctx, ss.FilePath, ss.Info)
+				sst.Duration = time.Now().Sub(sst.Start)
+				if err != nil {
+					setErr(fmt.Errorf("failed replacement in file %s: %w", ss.FilePath, err))
+					continue
+				}
+				if in.l.HasDebug() {
 
-						in.l.Debug().
-							Str("path", sst.FilePath).
-							Str("duration", sst.Duration.String()).
-							Bool("changed", changed).
-							Msg("Completed replacement in file")
-					}
-					if changed {
-						writtenCh <- sst
-					}
-					wg.Done()
+					in.l.Debug().
+						Str("path", sst.FilePath).
+						Str("duration", sst.Duration.String()).
+						Bool("changed", changed).
+						Msg("Completed replacement in file")
+				}
+				if changed {
+					mu.Lock()
+					written = append(written, sst)
+					mu.Unlock()
 				}
 			}
 		}()
 	}
 
-	go func() {
-		for {
-			select {
-			case sst := <-writtenCh:
-				written = append(written, sst)
-			}
-		}
-	}()
-
 	var walker filepath.WalkFunc = func(fPath string, info fs.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if info == nil {
 			return fmt.Errorf("fileInfo was nil for %s", fPath)
 		}
@@ -165,19 +176,21 @@ func (in Injecter) Inject() error {
 			}
 
 		}
-		paths[fPath] = info
-		ch <- s{fPath, info}
-		wg.Add(1)
+		count++
+		select {
+		case ch <- s{fPath, info}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 		return nil
 	}
-	if err := filepath.Walk(in.Dir, walker); err != nil {
-		return err
-	}
+	walkErr := filepath.Walk(in.Dir, walker)
+	close(ch)
 	debug := in.l.HasDebug()
 	if debug {
 		in.l.Debug().
 			Str("dir", in.Dir).
-			Int("count", len(paths)).
+			Int("count", count).
 			Int("concurrency", concurrency).
 			Msg("Started injection for files")
 
@@ -187,7 +200,7 @@ func (in Injecter) Inject() error {
 	if debug {
 		in.l.Debug().
 			Str("dir", in.Dir).
-			Int("count", len(paths)).
+			Int("count", count).
 			Int("concurrency", concurrency).
 			Int("writtenCount", len(written)).
 			Str("duration", time.Now().Sub(start).String()).
@@ -201,13 +214,22 @@ func (in Injecter) Inject() error {
 		}
 	}
 
-	return nil
+	if firstErr != nil {
+		return firstErr
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	return ctx.Err()
 
 }
-func (in Injecter) VisitFile(fPath string, info fs.FileInfo) (bool, error) {
+
+// VisitFile runs the replacement on the file. The file is replaced atomically,
+// and only if the context is not cancelled.
+func (in Injecter) VisitFile(ctx context.Context, fPath string, info fs.FileInfo) (bool, error) {
 	l := logger.With(in.l.With().Str("dir", in.Dir).Logger())
 
-	f, b, err := GetFileAndContent(in.DryRun, fPath, info)
+	b, err := os.ReadFile(fPath)
 	if err != nil {
 		return false, fmt.Errorf("Failed to read file %s: %w", fPath, err)
 	}
@@ -251,18 +273,12 @@ func (in Injecter) VisitFile(fPath string, info fs.FileInfo) (bool, error) {
 		fmt.Println(s)
 		return false, nil
 	}
-	f.Seek(0, 0)
-	n, err := f.Write([]byte(replacement))
-	if err != nil {
-		return true, fmt.Errorf("Error writing replacement to file '%s': %s",
-			fPath, err)
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
-	if int64(n) < info.Size() {
-		err := f.Truncate(int64(n))
-		if err != nil {
-			return true, fmt.Errorf("Error truncating file '%s' to size %d",
-				fPath, n)
-		}
+	if err := writeFileAtomic(fPath, []byte(replacement), info.Mode()); err != nil {
+		return true, fmt.Errorf("Error writing replacement to file '%s': %w",
+			fPath, err)
 	}
 	if in.OnReplaceCmd != "" {
 		if _, err := runCmd(in.OnReplaceCmd, fPath, strings.NewReader(s)); err != nil {
@@ -271,27 +287,3 @@ func (in Injecter) VisitFile(fPath string, info fs.FileInfo) (bool, error) {
 	}
 	return true, nil
 }
-
-func GetFileAndContent(dryRun bool, fn string, fi os.FileInfo) (f *os.File, content []byte, err error) {
-
-	if dryRun {
-		f, err = os.Open(fn)
-	} else {
-		f, err = os.OpenFile(fn, os.O_RDWR, 0666)
-	}
-
-	if err != nil {
-		return
-	}
-
-	content = make([]byte, fi.Size())
-	n, err := f.Read(content)
-	if err != nil {
-		return
-	}
-	if int64(n) != fi.Size() {
-		err = fmt.Errorf("Thw whole file was not read, only %s of %s", humanize.Bytes(uint64(n)), humanize.Bytes(uint64(fi.Size())))
-	}
-
-	return
-}
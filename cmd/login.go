@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/spf13/cobra"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in to skiver, and store the session for later use",
+	Long: `Log in to skiver, and store the session for later use.
+
+The session is stored per uri, and will be used automatically by other commands
+when no token is set.
+
+In CI, the password can be passed via stdin:
+
+  echo "$PASSWORD" | skiver login --username ci --password-stdin`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username := CLI.Login.Username
+		var password string
+		var err error
+		if username == "" {
+			if !isatty.IsTerminal(os.Stdin.Fd()) {
+				l.Fatal().Msg("Username is required when stdin is not a terminal")
+			}
+			fmt.Fprint(os.Stderr, "Username: ")
+			username, err = readLine()
+			if err != nil {
+				l.Fatal().Err(err).Msg("Failed to read username")
+			}
+		}
+		if CLI.Login.PasswordStdin {
+			b, err := io.ReadAll(stdinReader)
+			if err != nil {
+				l.Fatal().Err(err).Msg("Failed to read password from stdin")
+			}
+			password = strings.TrimRight(string(b), "\r\n")
+		} else {
+			if !isatty.IsTerminal(os.Stdin.Fd()) {
+				l.Fatal().Msg("Stdin is not a terminal. Use --password-stdin to pass the password via stdin")
+			}
+			password, err = readPassword("Password: ")
+			if err != nil {
+				l.Fatal().Err(err).Msg("Failed to read password")
+			}
+		}
+		api := requireApi(cmd.Context(), false)
+		if err := api.Login(cmd.Context(), username, password); err != nil {
+			fatal(err).Msg("Failed to log in")
+		}
+		cookie := api.TokenCookie()
+		if cookie == nil {
+			fatalCode(ExitAuth, nil).Msg("The server did not return a session-token")
+		}
+		store, err := loadCredentials()
+		if err != nil {
+			l.Fatal().Err(err).Msg("Failed to load stored credentials")
+		}
+		store.Set(CLI.URI, storedSession{
+			Token:     cookie.Value,
+			Username:  username,
+			Expires:   cookie.Expires,
+			CreatedAt: time.Now(),
+		})
+		if err := store.Save(); err != nil {
+			l.Fatal().Err(err).Msg("Failed to store session")
+		}
+		l.Info().Str("username", username).Str("uri", CLI.URI).Msg("Logged in")
+	},
+}
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out, and delete the stored session",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := loadCredentials()
+		if err != nil {
+			l.Fatal().Err(err).Msg("Failed to load stored credentials")
+		}
+		session, ok := store.Get(CLI.URI)
+		if !ok {
+			l.Info().Str("uri", CLI.URI).Msg("Not logged in")
+			return
+		}
+		// A configured token takes precedence elsewhere, but it is the stored session that is logged out
+		api := requireApi(cmd.Context(), false).WithToken(session.Token)
+		if err := api.Logout(cmd.Context()); err != nil {
+			l.Warn().Err(err).Msg("Failed to log out on the server. The stored session will still be deleted")
+		}
+		store.Delete(CLI.URI)
+		if err := store.Save(); err != nil {
+			l.Fatal().Err(err).Msg("Failed to delete stored session")
+		}
+		l.Info().Str("uri", CLI.URI).Msg("Logged out")
+	},
+}
+
+// whoamiCmd represents the whoami command
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Print the current user and organization",
+	Run: func(cmd *cobra.Command, args []string) {
+		api := requireApi(cmd.Context(), true)
+		session, err := api.Session(cmd.Context())
+		if err != nil {
+			fatal(err).Msg("Failed to get the current session")
+		}
+		marshalout(struct {
+			User         types.User         `json:"user"`
+			Organization types.Organization `json:"organization"`
+		}{session.User, session.Organization}, formatYaml)
+	},
+}
+
+func readLine() (string, error) {
+	s, err := stdinReader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return s, err
+	}
+	return strings.TrimRight(s, "\r\n"), nil
+}
+
+// readPassword prompts for a password, with echo turned off where possible.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if runtime.GOOS != "windows" {
+		if err := setEcho(false); err == nil {
+			defer func() {
+				setEcho(true)
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	return readLine()
+}
+
+func setEcho(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	c := exec.Command("stty", arg)
+	c.Stdin = os.Stdin
+	return c.Run()
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(whoamiCmd)
+	s := reflect.TypeOf(CLI.Login)
+	for _, v := range []string{"Username", "PasswordStdin"} {
+		mustSetVar(s, v, loginCmd, "login.")
+	}
+}
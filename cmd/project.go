@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+	"github.com/spf13/cobra"
+)
+
+// projectCmd represents the project command
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "List and inspect projects",
+}
+
+func formatLocales(ep types.ExtendedProject) string {
+	var locales []string
+	for _, loc := range ep.Locales {
+		locales = append(locales, loc.IETF)
+	}
+	sort.Strings(locales)
+	return strings.Join(locales, ",")
+}
+
+var projectListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all projects",
+	Long: `List all projects.
+
+With --stats, every project is exported to count its categories and translations.
+The exports run concurrently.`,
+	Args: cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		api := requireApi(cmd.Context(), true)
+		projects, err := api.Projects(cmd.Context())
+		if err != nil {
+			fatal(err).Msg("Failed to list projects")
+		}
+		sorted := make([]types.Project, 0, len(projects))
+		for _, p := range projects {
+			sorted = append(sorted, p)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].ShortName < sorted[j].ShortName
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if !CLI.ProjectList.Stats {
+			fmt.Fprintln(w, "SHORTNAME\tTITLE")
+			for _, p := range sorted {
+				fmt.Fprintf(w, "%s\t%s\n", p.ShortName, p.Title)
+			}
+			w.Flush()
+			return
+		}
+		exports, errs := exportProjects(cmd.Context(), *api, sorted)
+		fmt.Fprintln(w, "SHORTNAME\tTITLE\tLOCALES\tCATEGORIES\tTRANSLATIONS")
+		for i, p := range sorted {
+			if errs[i] != nil {
+				l.Warn().Err(errs[i]).Str("project", p.ShortName).Msg("Failed to get project-details")
+				fmt.Fprintf(w, "%s\t%s\t?\t?\t?\n", p.ShortName, p.Title)
+				continue
+			}
+			stats := buildProjectStats(exports[i])
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", p.ShortName, p.Title, formatLocales(exports[i]), stats.Categories, stats.Translations)
+		}
+		w.Flush()
+	},
+}
+
+// exportProjects exports every project concurrently. The results are in the same order as the projects.
+func exportProjects(ctx context.Context, api Api, projects []types.Project) ([]types.ExtendedProject, []error) {
+	exports := make([]types.ExtendedProject, len(projects))
+	errs := make([]error, len(projects))
+	sem := make(chan struct{}, maxConcurrentExports)
+	wg := sync.WaitGroup{}
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p types.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			exports[i], errs[i] = api.ExportProject(ctx, p.ID, CLI.Locale)
+		}(i, p)
+	}
+	wg.Wait()
+	return exports, errs
+}
+
+func init() {
+	rootCmd.AddCommand(projectCmd)
+	projectCmd.AddCommand(projectListCmd)
+	s := reflect.TypeOf(CLI.ProjectList)
+	for _, v := range []string{"Stats"} {
+		mustSetVar(s, v, projectListCmd, "project_list.")
+	}
+	projectCmd.AddCommand(&cobra.Command{
+		Use:   "show [project]",
+		Short: "Show details for a project. Defaults to the configured project",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			project := CLI.Project
+			if len(args) > 0 {
+				project = args[0]
+			}
+			if project == "" {
+				l.Fatal().Msg("Project is required")
+			}
+			api := requireApi(cmd.Context(), true)
+			ep, err := api.ExportProject(cmd.Context(), project, CLI.Locale)
+			if err != nil {
+				fatalExportError(cmd.Context(), l, *api, project, err, "Failed to get the project")
+			}
+			stats := buildProjectStats(ep)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "Title:\t%s\n", ep.Title)
+			fmt.Fprintf(w, "ShortName:\t%s\n", ep.ShortName)
+			fmt.Fprintf(w, "ID:\t%s\n", ep.ID)
+			fmt.Fprintf(w, "Categories:\t%d\n", stats.Categories)
+			fmt.Fprintf(w, "Translations:\t%d\n", stats.Translations)
+			w.Flush()
+
+			fmt.Println("\nLocales:")
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, id := range utils.SortedMapKeys(ep.Locales) {
+				loc := ep.Locales[id]
+				fmt.Fprintf(w, "  %s\t%d/%d translated\n", loc.IETF, stats.Values[id], stats.Translations)
+			}
+			w.Flush()
+
+			fmt.Println("\nCategories:")
+			w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			var categories []types.ExtendedCategory
+			walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+				if c.Key != "" {
+					categories = append(categories, c)
+				}
+			})
+			sort.Slice(categories, func(i, j int) bool {
+				return categories[i].Key < categories[j].Key
+			})
+			for _, c := range categories {
+				fmt.Fprintf(w, "  %s\t%s\t%d translations\n", c.Key, c.Title, len(c.Translations))
+			}
+			w.Flush()
+		},
+	})
+}
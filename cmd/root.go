@@ -22,12 +22,15 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path"
 	"reflect"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -54,11 +57,28 @@ func (u secret) MarshalTOML() ([]byte, error) {
 	return []byte(`""`), nil
 }
 
+// GenerateTarget is a file, or a set of files (one per locale) generated by 'skiver generate'.
+type GenerateTarget struct {
+	Name       string   `help:"Name of the target, used with 'skiver generate <name>'" json:"name" mapstructure:"name"`
+	Project    string   `help:"Project-id/ShortName. Defaults to the global project" json:"project,omitempty" mapstructure:"project"`
+	Format     string   `help:"Format of the export, like i18n, tKeys, or the local formats go, i18next, json-flat, typescript-typed and yaml" json:"format" mapstructure:"format"`
+	Template   string   `help:"Path to a go-template, used to render the output instead of a format" json:"template,omitempty" mapstructure:"template"`
+	Locale     string   `help:"Locale, comma-separated list of locales, or 'all'. Defaults to the global locale" json:"locale,omitempty" mapstructure:"locale"`
+	Path       string   `help:"Output-file. When generating multiple locales, this is a template like 'public/locales/{{.Locale}}/{{.Project}}.json'" json:"path" mapstructure:"path"`
+	Split      bool     `help:"Write a file per top-level category and locale, like 'locales/{{.Locale}}/{{.Category}}.json'" json:"split,omitempty" mapstructure:"split"`
+	SplitIndex string   `help:"Path of the index of namespaces, when splitting. Defaults to namespaces.json next to the files" json:"split_index,omitempty" mapstructure:"split_index"`
+	UsedIn     string   `help:"Only include translations referenced within the source-files of this directory" json:"used_in,omitempty" mapstructure:"used_in"`
+	Keep       []string `help:"Prefixes of keys to include when using used_in, even if they are not referenced, like 'errors.'" json:"keep,omitempty" mapstructure:"keep"`
+	// PostProcess is run with the path of every written file as the last argument
+	PostProcess  string `help:"Command to run on every written file, like 'eslint --fix'" json:"post_process,omitempty" mapstructure:"post_process"`
+	WithPrettier *bool  `help:"Run prettier on the written files. Defaults to the global with_prettier" json:"with_prettier,omitempty" mapstructure:"with_prettier"`
+}
+
 type config struct {
 	URI               string   `help:"Endpoint for skiver" env:"SKIVER_URI" short:"u" json:"uri"`
 	Project           string   `help:"Project-id/ShortName" short:"p" env:"SKIVER_PROJECT" json:"project"`
 	Token             secret   `help:"Token used for authentication" short:"t" env:"SKIVER_TOKEN" json:"token"`
-	Locale            string   `help:"Locale to use" env:"SKIVER_LOCALE" short:"l" json:"locale"`
+	Locale            string   `help:"Locale to use. For generate, this may be a comma-separated list, or 'all'" env:"SKIVER_LOCALE" short:"l" json:"locale"`
 	WithPrettier      bool     `help:"Where available, will attempt to run prettier, or prettier_d if available" json:"with_prettier"`
 	PrettierPath      string   `help:"Path-override for prettier" default:"prettier" json:"prettier_path"`
 	PrettierDSlimPath string   `help:"Path-override for prettier_d_slim, which should be faster than regular prettier" default:"prettier_d_slim" json:"prettier_d_slim_path"`
@@ -66,13 +86,44 @@ type config struct {
 	Color             string   `help:"Force set color output. one of 'auto', 'always', 'none'." json:"color"`
 	HighlightStyle    string   `help:"Highlighting-style to use. See https://github.com/alecthomas/chroma/tree/master/styles for valid styles" json:"highlight_style"`
 
+	RetryMax     int           `help:"Maximum number of retries for idempotent requests (export, server-info) on temporary failures" default:"3" env:"SKIVER_RETRY_MAX" json:"retry_max"`
+	RetryWaitMin time.Duration `help:"Minimum wait-time between retries. Doubled for every attempt" default:"500ms" env:"SKIVER_RETRY_WAIT_MIN" json:"retry_wait_min"`
+	RetryWaitMax time.Duration `help:"Maximum wait-time between retries, also used as a cap for Retry-After" default:"30s" env:"SKIVER_RETRY_WAIT_MAX" json:"retry_wait_max"`
+	Offline      bool          `help:"Use only the local export-cache, without contacting the server" env:"SKIVER_OFFLINE" json:"offline"`
+	NoCache      bool          `help:"Disable the local export-cache" env:"SKIVER_NO_CACHE" json:"no_cache"`
+	CacheDir     string        `help:"Directory for the local export-cache. Defaults to a directory within the users cache-directory" env:"SKIVER_CACHE_DIR" json:"cache_dir"`
+
+	CACert             string        `help:"Path to a PEM-encoded CA-bundle, trusted in addition to the system-roots" env:"SKIVER_CA_CERT" json:"ca_cert"`
+	ClientCert         string        `help:"Path to a PEM-encoded client-certificate, for mutual TLS" env:"SKIVER_CLIENT_CERT" json:"client_cert"`
+	ClientKey          string        `help:"Path to a PEM-encoded client-key, for mutual TLS" env:"SKIVER_CLIENT_KEY" json:"client_key"`
+	InsecureSkipVerify bool          `help:"Skip verification of the servers TLS-certificate. This is insecure, and should only be used for debugging" env:"SKIVER_INSECURE_SKIP_VERIFY" json:"insecure_skip_verify"`
+	Proxy              string        `help:"Url of a http-proxy. Defaults to HTTPS_PROXY/HTTP_PROXY/NO_PROXY from the environment" env:"SKIVER_PROXY" json:"proxy"`
+	Timeout            time.Duration `help:"Timeout for each request to the server" default:"1m" env:"SKIVER_TIMEOUT" json:"timeout"`
+
+	HTTPTrace  string `help:"Record every request and response to this file, as json-lines. Sensitive headers are redacted" env:"SKIVER_HTTP_TRACE" json:"http_trace"`
+	HTTPReplay string `help:"Serve responses from a file recorded with http-trace, instead of contacting the server" env:"SKIVER_HTTP_REPLAY" json:"http_replay"`
+
 	Import struct {
-		DryRun bool   `help:"Enable dry-run" json:"dry_run"`
-		Source string `help:"Source-file for import" arg:"" env:"SKIVER_IMPORT_SOURCE" json:"source"`
+		DryRun      bool   `help:"Enable dry-run" json:"dry_run"`
+		Source      string `help:"Source-file, directory or glob for import" arg:"" env:"SKIVER_IMPORT_SOURCE" json:"source"`
+		Pattern     string `help:"Pattern extracting the locale, and optionally the category, from the path of each file, like 'locales/{{.Locale}}/{{.Category}}.json'. Files are found by the pattern if the source is omitted" json:"pattern"`
+		Format      string `help:"Format of the source-file: gettext, i18next, json-flat or yaml. Detected from the extension and content if omitted" json:"format"`
+		Output      string `help:"Output of the import-report: text, json or yaml. Text is highlighted as a diff when stdout is a terminal" json:"output"`
+		Interactive bool   `help:"Review the changes of a dry-run one at a time, and import only the accepted changes" json:"interactive"`
+		Plan        string `help:"Import a plan saved with save-plan, instead of source-files" type:"existingfile" json:"plan"`
+		SavePlan    string `help:"Save the changes as a plan to import later with plan, instead of importing them. With interactive, only the accepted changes are saved" type:"path" json:"save_plan"`
+		OnConflict  string `help:"How to handle values that differ from the server: overwrite, skip, fail or only-empty. See 'skiver import --help'" default:"overwrite" json:"on_conflict"`
 	} `help:"Import from file" cmd:"" json:"import"`
 	Generate struct {
-		Path   string `help:"Ouput file to write to" type:"path" env:"SKIVER_GENERATE_PATH" json:"path"`
-		Format string `help:"Generate files from export. Common formats are: i18n,tKeys." json:"format" required:"true"`
+		Path       string           `help:"Ouput file to write to. When generating multiple locales, this is a template like 'public/locales/{{.Locale}}/{{.Project}}.json'" type:"path" env:"SKIVER_GENERATE_PATH" json:"path"`
+		Format     string           `help:"Generate files from export. Common formats are: i18n,tKeys. The formats go,i18next,json-flat,typescript-typed,yaml are rendered locally" json:"format" required:"true"`
+		Template   string           `help:"Path to a go-template, used to render the output instead of a format. See 'skiver generate --help'" type:"path" json:"template"`
+		Split      bool             `help:"Write a file per top-level category and locale, for use as i18next-namespaces. The path must contain {{.Category}}" json:"split"`
+		SplitIndex string           `help:"Path of the index of namespaces, when splitting. Defaults to namespaces.json next to the files" json:"split_index"`
+		UsedIn     string           `help:"Only include translations referenced within the source-files of this directory (tree-shaking)" type:"existingdir" json:"used_in"`
+		Keep       []string         `help:"Prefixes of keys to include with used-in, even if they are not referenced, like 'errors.'" json:"keep"`
+		Check      bool             `help:"Do not write any files, but exit non-zero with a diff if the generated files differ from the files on disk" json:"check"`
+		Targets    []GenerateTarget `help:"Targets to generate with a bare 'skiver generate'. A single target can be generated with 'skiver generate <name>'" json:"targets" mapstructure:"targets"`
 	} `help:"Generate files from project etc." cmd:"" json:"generate"`
 	Unused struct {
 		Source string `help:"Source-file to check-against. If ommitted, the upstream project is used as source" json:"source"`
@@ -85,6 +136,22 @@ type config struct {
 		Dir       string `help:"Directory for source-code" type:"existingdir" arg:"" json:"dir"`
 		Type      string `help:"Type of injection. Can be either 'comment', or 'tKeys'" json:"type"`
 	} `help:"Inject helper-comments into source-files" cmd:"" json:"inject"`
+	Login struct {
+		Username      string `help:"Username to log in with. Will be prompted for if omitted" env:"SKIVER_USERNAME" json:"username"`
+		PasswordStdin bool   `help:"Read the password from stdin, for use in CI" json:"password_stdin"`
+	} `help:"Log in to skiver" cmd:"" json:"login"`
+	ProjectList struct {
+		Stats bool `help:"Also show the locales, categories and translations of every project. This exports every project" json:"stats"`
+	} `help:"List all projects" cmd:"" json:"project_list"`
+	Translation struct {
+		DryRun  bool   `help:"Enable dry-run" json:"dry_run"`
+		Context string `help:"Context-key for the translation-value, like 'male'" json:"context"`
+	} `help:"Create, update and delete single translations" cmd:"" json:"translation"`
+	Category struct {
+		DryRun      bool   `help:"Enable dry-run" json:"dry_run"`
+		Title       string `help:"Title of the category. Defaults to the key" json:"title"`
+		Description string `help:"Description of the category" json:"description"`
+	} `help:"Manage categories" cmd:"" json:"category"`
 	Config struct {
 		Format string `enum:"json,yaml,toml" default:"toml" json:"format"`
 	} `help:"Configuration" cmd:"" json:"config"`
@@ -126,23 +193,12 @@ func init() {
 	isDev = version == ""
 }
 
-func requireApi(withAuthentciation bool) *Api {
+func requireApi(ctx context.Context, withAuthentciation bool) *Api {
 	if _api == nil {
-		if CLI.URI == "" {
-			l.Fatal().Msg("URI is required")
+		_api = newApiFromConfig(withAuthentciation)
+		if !CLI.Offline {
+			_api.verifyLatestVersion(ctx)
 		}
-		if withAuthentciation && CLI.Token == "" {
-			l.Fatal().Msg("Token is required")
-		}
-
-		a := NewAPI(l, CLI.URI)
-		a.Headers.Set("CLIENT_APP", "skiver-cli")
-		a.Headers.Set("CLIENT_VERSION", version)
-		a.Headers.Set("CLIENT_HASH", commit)
-		_api = &a
-		a.verifyLatestVersion()
-
-		_api.SetToken(string(CLI.Token))
 	}
 	if _api == nil {
 		l.Fatal().Msg("Api is not initialized")
@@ -150,10 +206,92 @@ func requireApi(withAuthentciation bool) *Api {
 	return _api
 }
 
+// newApiFromConfig creates an Api from the configuration, without any version-check
+func newApiFromConfig(withAuthentciation bool) *Api {
+	if CLI.URI == "" {
+		fatalCode(ExitUsage, nil).Msg("URI is required")
+	}
+	token := string(CLI.Token)
+	if token == "" && !CLI.Offline {
+		token = storedToken(CLI.URI)
+	}
+	if withAuthentciation && token == "" && !CLI.Offline && CLI.HTTPReplay == "" {
+		fatalCode(ExitAuth, nil).Msg("Token is required. Use 'skiver login', or set it in the configuration")
+	}
+	if CLI.Offline && CLI.NoCache {
+		fatalCode(ExitUsage, nil).Msg("Offline and NoCache cannot be used at the same time")
+	}
+
+	if CLI.InsecureSkipVerify {
+		l.Warn().Msg("!!! TLS-verification is disabled (insecure-skip-verify). The connection to the server is NOT secure, and may be intercepted !!!")
+	}
+	a, err := NewAPI(l, CLI.URI, ClientOptions{
+		CACert:             CLI.CACert,
+		ClientCert:         CLI.ClientCert,
+		ClientKey:          CLI.ClientKey,
+		InsecureSkipVerify: CLI.InsecureSkipVerify,
+		Proxy:              CLI.Proxy,
+		Timeout:            CLI.Timeout,
+		HTTPTrace:          CLI.HTTPTrace,
+		HTTPReplay:         CLI.HTTPReplay,
+	})
+	if err != nil {
+		l.Fatal().Err(err).Msg("Failed to create the http-client")
+	}
+	a.MaxRetries = CLI.RetryMax
+	if CLI.RetryWaitMin > 0 {
+		a.RetryWaitMin = CLI.RetryWaitMin
+	}
+	if CLI.RetryWaitMax > 0 {
+		a.RetryWaitMax = CLI.RetryWaitMax
+	}
+	a.Headers.Set("CLIENT_APP", "skiver-cli")
+	a.Headers.Set("CLIENT_VERSION", version)
+	a.Headers.Set("CLIENT_HASH", commit)
+	a.Offline = CLI.Offline
+	a.Replaying = CLI.HTTPReplay != ""
+	// The cache would make replays depend on earlier runs
+	if !CLI.NoCache && CLI.HTTPReplay == "" {
+		cache, err := NewExportCache(CLI.CacheDir)
+		if err != nil {
+			if CLI.Offline {
+				l.Fatal().Err(err).Msg("Failed to initialize the export-cache")
+			}
+			l.Warn().Err(err).Msg("Failed to initialize the export-cache, continuing without it")
+		}
+		a.Cache = cache
+	}
+	a.SetToken(token)
+	return &a
+}
+
+// storedToken returns the token of a session stored with 'skiver login', if any.
+func storedToken(uri string) string {
+	store, err := loadCredentials()
+	if err != nil {
+		l.Warn().Err(err).Msg("Failed to load stored credentials")
+		return ""
+	}
+	session, ok := store.Get(uri)
+	if !ok {
+		return ""
+	}
+	if session.Expired() {
+		l.Warn().
+			Str("uri", uri).
+			Time("expired", session.Expires).
+			Msg("The stored session has expired. Use 'skiver login' to log in again")
+		return ""
+	}
+	l.Debug().Str("uri", uri).Str("username", session.Username).Msg("Using stored session")
+	return session.Token
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "skiver",
 	Short: "Interactions with skiver, a developer-focused translation-service",
+	Long:  "Interactions with skiver, a developer-focused translation-service\n\n" + exitCodesHelp,
 
 	Version: version,
 
@@ -167,9 +305,17 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// The first interrupt cancels the context, so that in-flight requests are
+	// aborted and no new file-writes are started. A second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		// Commands handle their own errors, so any error here is from cobra, like unknown flags.
+		os.Exit(ExitUsage)
 	}
 }
 
@@ -180,7 +326,7 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (See 'skiver config --help' for the default config-paths )")
 
 	s := reflect.TypeOf(CLI)
-	for _, v := range []string{"HighlightStyle", "Color", "Project", "WithPrettier", "PrettierPath", "PrettierDSlimPath", "LogFormat", "LogLevel", "URI", "Locale", "Token", "IgnoreFilter"} {
+	for _, v := range []string{"HighlightStyle", "Color", "Project", "WithPrettier", "PrettierPath", "PrettierDSlimPath", "LogFormat", "LogLevel", "URI", "Locale", "Token", "IgnoreFilter", "RetryMax", "RetryWaitMin", "RetryWaitMax", "Offline", "NoCache", "CacheDir", "CACert", "ClientCert", "ClientKey", "InsecureSkipVerify", "Proxy", "Timeout", "HTTPTrace", "HTTPReplay"} {
 		mustSetVar(s, v, rootCmd, "")
 	}
 
@@ -333,6 +479,16 @@ func setVar(t reflect.Type, name string, cmd *cobra.Command, subkey string) erro
 			defaultInt = int(n)
 		}
 		cmd.PersistentFlags().IntP(cfgName, short, defaultInt, desc)
+	case "Duration":
+		var defaultDuration time.Duration
+		if defaultStr != "" {
+			d, err := time.ParseDuration(defaultStr)
+			if err != nil {
+				panic(fmt.Sprintf("failed to convert default-tag (%s) on config-field %s", defaultStr, field.Name))
+			}
+			defaultDuration = d
+		}
+		cmd.PersistentFlags().DurationP(cfgName, short, defaultDuration, desc)
 	case "[]string":
 		var defaultStrings []string
 		if defaultStr != "" {
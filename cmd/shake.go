@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+)
+
+// projectKeys returns the full keys of every translation within the project
+func projectKeys(ep types.ExtendedProject) map[string]struct{} {
+	keys := map[string]struct{}{}
+	walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+		for _, t := range c.Translations {
+			key := t.Key
+			if c.Key != "" {
+				key = c.Key + "." + t.Key
+			}
+			keys[key] = struct{}{}
+			// Plurals like 'item_one' are used as 'item'
+			if loc := i18nextPluralSuffix.FindStringIndex(key); loc != nil {
+				keys[key[:loc[0]]] = struct{}{}
+			}
+		}
+	})
+	return keys
+}
+
+// usedKeyFilter returns a function reporting whether a key is used within the source-files
+// in dir, or matches one of the prefixes to keep.
+func usedKeyFilter(ctx context.Context, ep types.ExtendedProject, dir string, keep []string) (func(key string) bool, error) {
+	keys := projectKeys(ep)
+	if len(keys) == 0 {
+		return func(string) bool { return false }, nil
+	}
+	regex := buildTranslationKeyRegexFromMap(utils.SortedMapKeys(keys))
+	used, err := findUsedKeys(ctx, dir, regex)
+	if err != nil {
+		return nil, err
+	}
+	l.Debug().Int("used", len(used)).Int("keys", len(keys)).Str("dir", dir).Msg("Found used translation-keys")
+	return func(key string) bool {
+		if used[key] {
+			return true
+		}
+		if loc := i18nextPluralSuffix.FindStringIndex(key); loc != nil && used[key[:loc[0]]] {
+			return true
+		}
+		for _, prefix := range keep {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// shakeProject returns a copy of the project with only the translations accepted by the filter.
+// Categories without any remaining translations are removed.
+func shakeProject(ep types.ExtendedProject, filter func(key string) bool) types.ExtendedProject {
+	ep.CategoryTree, _ = shakeCategory(ep.CategoryTree, filter)
+	return ep
+}
+
+func shakeCategory(c types.ExtendedCategory, filter func(key string) bool) (types.ExtendedCategory, bool) {
+	translations := map[string]types.ExtendedTranslation{}
+	for id, t := range c.Translations {
+		key := t.Key
+		if c.Key != "" {
+			key = c.Key + "." + t.Key
+		}
+		if filter(key) {
+			translations[id] = t
+		}
+	}
+	c.Translations = translations
+	categories := map[string]types.ExtendedCategory{}
+	for id, child := range c.Categories {
+		if shaken, ok := shakeCategory(child, filter); ok {
+			categories[id] = shaken
+		}
+	}
+	c.Categories = categories
+	return c, len(translations) > 0 || len(categories) > 0
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/runar-rkmedia/skiver/types"
+)
+
+// rootNamespace is the namespace for translations that are not within any category.
+// This is the default namespace of i18next.
+const rootNamespace = "translation"
+
+// splitNamespaces splits the project into one project per top-level category, keyed by the
+// key of the category. The keys within each project are relative to the category.
+// A top-level category with the same key as the rootNamespace is an error, when there are
+// also translations outside of any category, since both would be written to the same file.
+func splitNamespaces(ep types.ExtendedProject) (map[string]types.ExtendedProject, error) {
+	namespaces := map[string]types.ExtendedProject{}
+	if len(ep.CategoryTree.Translations) > 0 {
+		sub := ep
+		sub.CategoryTree = types.ExtendedCategory{Translations: ep.CategoryTree.Translations}
+		namespaces[rootNamespace] = sub
+	}
+	for _, c := range ep.CategoryTree.Categories {
+		if _, ok := namespaces[c.Key]; ok {
+			return nil, fmt.Errorf("the category '%s' and the translations without a category would both be written to the namespace '%s'. Move the translations into a category", c.Key, rootNamespace)
+		}
+		sub := ep
+		sub.CategoryTree = rekeyCategory(c, c.Key)
+		namespaces[c.Key] = sub
+	}
+	return namespaces, nil
+}
+
+// rekeyCategory returns a copy of the category-tree, with the prefix removed from the keys.
+func rekeyCategory(c types.ExtendedCategory, prefix string) types.ExtendedCategory {
+	if c.Key == prefix {
+		c.Key = ""
+	} else {
+		c.Key = strings.TrimPrefix(c.Key, prefix+".")
+	}
+	if c.Categories == nil {
+		return c
+	}
+	categories := make(map[string]types.ExtendedCategory, len(c.Categories))
+	for id, child := range c.Categories {
+		categories[id] = rekeyCategory(child, prefix)
+	}
+	c.Categories = categories
+	return c
+}
+
+// splitIndexPath returns the path of the index-file for a split target. Unless set, it is
+// placed in the static part of the path-template, like 'locales/namespaces.json' for
+// 'locales/{{.Locale}}/{{.Category}}.json'.
+func splitIndexPath(t GenerateTarget) string {
+	if t.SplitIndex != "" {
+		return t.SplitIndex
+	}
+	static := t.Path
+	if i := strings.Index(static, "{{"); i >= 0 {
+		static = static[:i]
+	}
+	dir := static
+	if !strings.HasSuffix(static, "/") && !strings.HasSuffix(static, string(filepath.Separator)) {
+		dir = filepath.Dir(static)
+	}
+	return filepath.Join(dir, "namespaces.json")
+}
+
+// namespaceIndex lists the namespaces and locales of a split target, for use in the configuration of i18next.
+type namespaceIndex struct {
+	Namespaces []string `json:"namespaces"`
+	Locales    []string `json:"locales"`
+}
+
+func buildNamespaceIndex(files []generatedFile) ([]byte, error) {
+	index := namespaceIndex{Namespaces: []string{}, Locales: []string{}}
+	seenNs, seenLoc := map[string]bool{}, map[string]bool{}
+	for _, f := range files {
+		if !seenNs[f.Category] {
+			seenNs[f.Category] = true
+			index.Namespaces = append(index.Namespaces, f.Category)
+		}
+		if !seenLoc[f.Locale] {
+			seenLoc[f.Locale] = true
+			index.Locales = append(index.Locales, f.Locale)
+		}
+	}
+	sort.Strings(index.Namespaces)
+	return marshalJSON(index)
+}
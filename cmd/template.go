@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+	"github.com/stoewer/go-strcase"
+)
+
+// templateData is the data available within user-defined templates for generate.
+type templateData struct {
+	// Project is the raw export of the project
+	Project types.ExtendedProject
+	// Locale is the locale being rendered
+	Locale types.Locale
+	// Locales are all the locales within the project, sorted by IETF-tag
+	Locales []types.Locale
+	// Translations is the flattened project, keyed by the translation-key, then the IETF-tag of the locale.
+	// Contexts are keyed by '<locale>_<context>'
+	Translations map[string]map[string]string
+	// Keys are the sorted keys of Translations
+	Keys []string
+	// Values are the values for the current locale, keyed by the translation-key.
+	// Contexts are keyed by '<key>_<context>'
+	Values map[string]string
+}
+
+// templateFuncs are the helpers available in user-defined templates, in addition to the sprig-functions.
+var templateFuncs = template.FuncMap{
+	"lowerCamel": keyCase(strcase.LowerCamelCase),
+	"upperCamel": keyCase(strcase.UpperCamelCase),
+	"snake":      keyCase(strcase.SnakeCase),
+	"upperSnake": keyCase(strcase.UpperSnakeCase),
+	"kebab":      keyCase(strcase.KebabCase),
+	"upperKebab": keyCase(strcase.UpperKebabCase),
+
+	// jsonString returns s as a quoted json-string
+	"jsonString": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+	// goString returns s as a quoted go-string. This is also valid for many c-like languages
+	"goString":   strconv.Quote,
+	"escapeHTML": html.EscapeString,
+	"escapeXML":  html.EscapeString,
+	// csvField quotes the field, if required
+	"csvField": func(s string) string {
+		if !strings.ContainsAny(s, ",\"\r\n") {
+			return s
+		}
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	},
+	// shellQuote quotes s for use in posix-shells, and env-files
+	"shellQuote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+// keyCase wraps a case-conversion, so that the dots within translation-keys are treated as word-boundaries
+func keyCase(fn func(string) string) func(string) string {
+	return func(s string) string {
+		return fn(strings.ReplaceAll(s, ".", "_"))
+	}
+}
+
+// parseGenerateTemplate parses the user-defined template-file.
+func parseGenerateTemplate(path string) (*template.Template, error) {
+	t := template.New(filepath.Base(path)).
+		Funcs(sprig.TxtFuncMap()).
+		Funcs(templateFuncs).
+		// The locale-lookups are replaced for every locale
+		Funcs(localeLookupFuncs(templateData{}))
+	t, err := t.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the template %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// localeLookupFuncs returns the template-helpers for looking up values
+func localeLookupFuncs(data templateData) template.FuncMap {
+	return template.FuncMap{
+		// value returns the value of the key within the current locale
+		"value": func(key string) string {
+			return data.Values[key]
+		},
+		// valueFor returns the value of the key within the locale
+		"valueFor": func(localeLike, key string) (string, error) {
+			loc, ok := findLocale(data.Project, localeLike)
+			if !ok {
+				return "", fmt.Errorf("locale '%s' was not found within the project", localeLike)
+			}
+			return data.Translations[key][loc.IETF], nil
+		},
+		// locale returns the locale matching the locale-like string
+		"locale": func(localeLike string) (types.Locale, error) {
+			loc, ok := findLocale(data.Project, localeLike)
+			if !ok {
+				return loc, fmt.Errorf("locale '%s' was not found within the project", localeLike)
+			}
+			return loc, nil
+		},
+	}
+}
+
+// renderTemplate renders the template for the locale, from the raw export.
+func renderTemplate(tmpl *template.Template, ep types.ExtendedProject, localeLike string) ([]byte, error) {
+	locale, ok := findLocale(ep, localeLike)
+	if !ok {
+		return nil, fmt.Errorf("locale '%s' was not found within the project. Available locales: %s", localeLike, formatLocales(ep))
+	}
+	data := templateData{
+		Project: ep,
+		Locale:  locale,
+		Values:  localeValues(ep, locale),
+	}
+	var ietfs []string
+	for _, loc := range ep.Locales {
+		data.Locales = append(data.Locales, loc)
+		ietfs = append(ietfs, loc.IETF)
+	}
+	sort.Slice(data.Locales, func(i, j int) bool { return data.Locales[i].IETF < data.Locales[j].IETF })
+	flat, err := FlattenExtendedProject(ep, ietfs)
+	if err != nil {
+		return nil, err
+	}
+	data.Translations = flat
+	data.Keys = utils.SortedMapKeys(flat)
+
+	t, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	t.Funcs(localeLookupFuncs(data))
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute the template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
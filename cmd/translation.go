@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/runar-rkmedia/skiver/models"
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/spf13/cobra"
+)
+
+// translationCmd represents the translation command
+var translationCmd = &cobra.Command{
+	Use:   "translation",
+	Short: "Create, update and delete single translations",
+}
+
+// categoryCmd represents the category command
+var categoryCmd = &cobra.Command{
+	Use:   "category",
+	Short: "Manage categories",
+}
+
+func requireProjectForEdit(ctx context.Context, api *Api, locale string) types.ExtendedProject {
+	if CLI.Project == "" {
+		l.Fatal().Msg("Project is required")
+	}
+	ep, err := api.ExportProject(ctx, CLI.Project, locale)
+	if err != nil {
+		fatalExportError(ctx, l, *api, CLI.Project, err, "Failed to get the project")
+	}
+	return ep
+}
+
+func init() {
+	rootCmd.AddCommand(translationCmd)
+	rootCmd.AddCommand(categoryCmd)
+	translationCmd.AddCommand(&cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Create or update the value of a translation for a locale",
+		Example: `  skiver translation set foo.bar --locale en "Hello"
+  skiver translation set foo.bar --locale en --context male "Hello sir"`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			key, value := args[0], args[1]
+			if CLI.Locale == "" {
+				l.Fatal().Msg("Locale is required")
+			}
+			api := requireApi(cmd.Context(), true)
+			api.DryRun = CLI.Translation.DryRun
+			ep := requireProjectForEdit(cmd.Context(), api, CLI.Locale)
+			locale, ok := findLocale(ep, CLI.Locale)
+			if !ok {
+				l.Fatal().Str("locale", CLI.Locale).Msg("Locale not found in project")
+			}
+			categoryKey, translationKey := splitTranslationKey(key)
+			category, ok := findCategory(ep.CategoryTree, categoryKey)
+			if !ok {
+				l.Fatal().
+					Str("category", categoryKey).
+					Msgf("Category not found. It can be created with 'skiver category create %s'", categoryKey)
+			}
+			ll := l.With().
+				Str("key", key).
+				Str("locale", locale.IETF).
+				Str("context", CLI.Translation.Context).
+				Bool("dry-run", api.DryRun).
+				Logger()
+
+			var translation types.ExtendedTranslation
+			exists := false
+			for _, t := range category.Translations {
+				if t.Key == translationKey {
+					translation, exists = t, true
+					break
+				}
+			}
+			translationID := translation.ID
+			if !exists {
+				created, err := api.CreateTranslation(cmd.Context(), models.TranslationInput{
+					CategoryID: ptr(category.ID),
+					Key:        ptr(translationKey),
+				})
+				if err != nil {
+					fatalWith(ll, err).Msg("Failed to create translation")
+				}
+				translationID = created.ID
+				ll.Info().Msg("Created translation")
+				if translationID == "" {
+					ll.Info().Msg("The translation-value will be created once the translation exists")
+					return
+				}
+			}
+
+			for _, tv := range translation.Values {
+				if tv.LocaleID != locale.ID {
+					continue
+				}
+				_, err := api.UpdateTranslationValue(cmd.Context(), models.UpdateTranslationValueInput{
+					ID:         ptr(tv.ID),
+					Value:      ptr(value),
+					ContextKey: CLI.Translation.Context,
+				})
+				if err != nil {
+					fatalWith(ll, err).Msg("Failed to update translation-value")
+				}
+				ll.Info().Msg("Updated translation-value")
+				return
+			}
+			_, err := api.CreateTranslationValue(cmd.Context(), models.TranslationValueInput{
+				LocaleID:      ptr(locale.ID),
+				TranslationID: ptr(translationID),
+				Value:         ptr(value),
+				ContextKey:    CLI.Translation.Context,
+			})
+			if err != nil {
+				fatalWith(ll, err).Msg("Failed to create translation-value")
+			}
+			ll.Info().Msg("Created translation-value")
+		},
+	})
+	translationCmd.AddCommand(&cobra.Command{
+		Use:     "rm <key>",
+		Aliases: []string{"delete"},
+		Short:   "Delete a translation, including all its values",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := args[0]
+			api := requireApi(cmd.Context(), true)
+			api.DryRun = CLI.Translation.DryRun
+			ep := requireProjectForEdit(cmd.Context(), api, CLI.Locale)
+			categoryKey, translationKey := splitTranslationKey(key)
+			category, ok := findCategory(ep.CategoryTree, categoryKey)
+			if !ok {
+				l.Fatal().Str("category", categoryKey).Msg("Category not found")
+			}
+			for _, t := range category.Translations {
+				if t.Key != translationKey {
+					continue
+				}
+				if err := api.DeleteTranslation(cmd.Context(), t.ID); err != nil {
+					fatal(err).Str("key", key).Msg("Failed to delete translation")
+				}
+				l.Info().Str("key", key).Bool("dry-run", api.DryRun).Msg("Deleted translation")
+				return
+			}
+			l.Fatal().Str("key", key).Msg("Translation not found")
+		},
+	})
+	categoryCmd.AddCommand(&cobra.Command{
+		Use:   "create <key>",
+		Short: "Create a category",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			key := args[0]
+			api := requireApi(cmd.Context(), true)
+			api.DryRun = CLI.Category.DryRun
+			ep := requireProjectForEdit(cmd.Context(), api, CLI.Locale)
+			if _, exists := findCategory(ep.CategoryTree, key); exists {
+				l.Fatal().Str("key", key).Msg("Category already exists")
+			}
+			title := CLI.Category.Title
+			if title == "" {
+				title = key
+			}
+			_, err := api.CreateCategory(cmd.Context(), models.CategoryInput{
+				Key:         ptr(key),
+				ProjectID:   ptr(ep.ID),
+				Title:       ptr(title),
+				Description: CLI.Category.Description,
+			})
+			if err != nil {
+				fatal(err).Str("key", key).Msg("Failed to create category")
+			}
+			l.Info().Str("key", key).Bool("dry-run", api.DryRun).Msg("Created category")
+		},
+	})
+
+	s := reflect.TypeOf(CLI.Translation)
+	for _, v := range []string{"DryRun", "Context"} {
+		mustSetVar(s, v, translationCmd, "translation.")
+	}
+	s = reflect.TypeOf(CLI.Category)
+	for _, v := range []string{"DryRun", "Title", "Description"} {
+		mustSetVar(s, v, categoryCmd, "category.")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/runar-rkmedia/skiver/types"
+	"github.com/runar-rkmedia/skiver/utils"
+)
+
+var (
+	// i18nextInterpolation matches interpolations like {{name}}, {{- name}} and {{value, number}}
+	i18nextInterpolation = regexp.MustCompile(`{{-?\s*([^{}\s,]+)\s*(?:,\s*([^{}]*?))?\s*}}`)
+	// i18nextPluralSuffix matches the plural-suffixes of i18next v4 (_one, _other...) and v3 (_plural, _0...)
+	i18nextPluralSuffix = regexp.MustCompile(`_(zero|one|two|few|many|other|plural|\d+)$`)
+	tsIdentifier        = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// tsKeyInfo holds what is required to call t() for a single translation-key
+type tsKeyInfo struct {
+	// Params are the interpolated parameters, with their possible types
+	Params   map[string]map[string]bool
+	Contexts map[string]bool
+	Plural   bool
+}
+
+func (info *tsKeyInfo) addParam(name, typ string) {
+	if info.Params[name] == nil {
+		info.Params[name] = map[string]bool{}
+	}
+	info.Params[name][typ] = true
+}
+
+// parseValue adds the interpolations found within the value to info.
+func (info *tsKeyInfo) parseValue(value string) {
+	for _, m := range i18nextInterpolation.FindAllStringSubmatch(value, -1) {
+		name, format := m[1], strings.TrimSpace(m[2])
+		typ := "string | number"
+		if i := strings.Index(name, "."); i > 0 {
+			// Nested, like {{user.name}}
+			name = name[:i]
+			typ = "Record<string, unknown>"
+		} else {
+			switch strings.SplitN(format, "(", 2)[0] {
+			case "number", "currency", "relativetime":
+				typ = "number"
+			case "datetime":
+				typ = "Date"
+			}
+		}
+		info.addParam(name, typ)
+	}
+}
+
+// addContext registers a context, like 'male', 'one' or 'male_other', which may also be a plural-form.
+func (info *tsKeyInfo) addContext(ctx string) {
+	if i18nextPluralSuffix.MatchString("_" + ctx) {
+		info.Plural = true
+		return
+	}
+	if loc := i18nextPluralSuffix.FindStringIndex(ctx); loc != nil {
+		info.Plural = true
+		ctx = ctx[:loc[0]]
+	}
+	if ctx != "" {
+		info.Contexts[ctx] = true
+	}
+}
+
+// buildTSKeyInfos parses every value within the project, across all locales, so that
+// a parameter used in any locale is required.
+func buildTSKeyInfos(ep types.ExtendedProject) map[string]*tsKeyInfo {
+	infos := map[string]*tsKeyInfo{}
+	walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+		for _, t := range c.Translations {
+			key := t.Key
+			if c.Key != "" {
+				key = c.Key + "." + t.Key
+			}
+			plural := false
+			if loc := i18nextPluralSuffix.FindStringIndex(key); loc != nil {
+				key = key[:loc[0]]
+				plural = true
+			}
+			info, ok := infos[key]
+			if !ok {
+				info = &tsKeyInfo{Params: map[string]map[string]bool{}, Contexts: map[string]bool{}}
+				infos[key] = info
+			}
+			if plural {
+				info.Plural = true
+			}
+			for _, tv := range t.Values {
+				info.parseValue(tv.Value)
+				for ctx, v := range tv.Context {
+					info.addContext(ctx)
+					info.parseValue(v)
+				}
+			}
+		}
+	})
+	for _, info := range infos {
+		if info.Plural {
+			info.Params["count"] = map[string]bool{"number": true}
+		}
+	}
+	return infos
+}
+
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// paramsType returns the typescript-type of the options-object for t()
+func (info tsKeyInfo) paramsType() string {
+	if len(info.Params) == 0 && len(info.Contexts) == 0 {
+		return "Record<string, never>"
+	}
+	var fields []string
+	for _, name := range utils.SortedMapKeys(info.Params) {
+		paramTypes := utils.SortedMapKeys(info.Params[name])
+		fields = append(fields, fmt.Sprintf("%s: %s", tsPropertyName(name), strings.Join(paramTypes, " | ")))
+	}
+	if len(info.Contexts) > 0 {
+		var contexts []string
+		for _, c := range utils.SortedMapKeys(info.Contexts) {
+			contexts = append(contexts, strconv.Quote(c))
+		}
+		fields = append(fields, "context?: "+strings.Join(contexts, " | "))
+	}
+	return "{ " + strings.Join(fields, "; ") + " }"
+}
+
+// writeTSKeys writes the nested object of translation-keys
+func writeTSKeys(sb *strings.Builder, m map[string]interface{}, indent string) {
+	for _, k := range utils.SortedMapKeys(m) {
+		switch v := m[k].(type) {
+		case string:
+			fmt.Fprintf(sb, "%s%s: %s,\n", indent, tsPropertyName(k), strconv.Quote(v))
+		case map[string]interface{}:
+			fmt.Fprintf(sb, "%s%s: {\n", indent, tsPropertyName(k))
+			writeTSKeys(sb, v, indent+"  ")
+			fmt.Fprintf(sb, "%s},\n", indent)
+		}
+	}
+}
+
+// formatTypescriptTyped renders the translation-keys as constants, with a type mapping each key
+// to the parameters required by t().
+func formatTypescriptTyped(ep types.ExtendedProject, _ types.Locale, _ formatOptions) ([]byte, error) {
+	infos := buildTSKeyInfos(ep)
+	flat := map[string]string{}
+	for k := range infos {
+		flat[k] = k
+	}
+	nested, err := nestKeys(flat)
+	if err != nil {
+		return nil, err
+	}
+	sb := strings.Builder{}
+	sb.WriteString("// This file is generated by skiver-cli. Do not edit.\n\n")
+	sb.WriteString("export const tKeys = {\n")
+	writeTSKeys(&sb, nested, "  ")
+	sb.WriteString("} as const\n\n")
+
+	sb.WriteString("/** The parameters required by each translation-key */\n")
+	sb.WriteString("export interface TranslationParams {\n")
+	keys := make([]string, 0, len(infos))
+	for k := range infos {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "  %s: %s\n", strconv.Quote(k), infos[k].paramsType())
+	}
+	sb.WriteString("}\n\n")
+	sb.WriteString("export type TranslationKey = keyof TranslationParams\n\n")
+	sb.WriteString("/** A typed t-function. The parameters are only optional for keys without any */\n")
+	sb.WriteString("export type TFunction = <K extends TranslationKey>(\n")
+	sb.WriteString("  key: K,\n")
+	sb.WriteString("  ...params: {} extends TranslationParams[K] ? [TranslationParams[K]?] : [TranslationParams[K]]\n")
+	sb.WriteString(") => string\n")
+	return []byte(sb.String()), nil
+}
@@ -22,10 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 )
@@ -34,41 +38,20 @@ import (
 var unusedCmd = &cobra.Command{
 	Use:   "unused",
 	Short: "Find unused translations",
+	Long:  "Find unused translations.\n\nExits with code 10 if any unused translation-keys were found.",
 	Run: func(cmd *cobra.Command, args []string) {
 		if CLI.Unused.Dir == "" {
 			l.Fatal().Msg("Dir is required")
 		}
-		api := requireApi(true)
+		api := requireApi(cmd.Context(), true)
 		// TODO: also check if translations are used within other translations.
 		// For instance, a translation may only be used by refereance.
 		source, _ := getFile(CLI.Unused.Source)
-		translationKeys, regex := buildTranslationMapWithRegex(l, source, *api, CLI.Project, CLI.Locale)
-		found := map[string]bool{}
-		foundCh := make(chan string)
-		quitCh := make(chan struct{})
-		replacementFunc := func(groups []string) (replacement string, changed bool) {
-			foundCh <- groups[1]
-			return "", false
-		}
-
-		go func() {
-			for {
-				select {
-				case <-quitCh:
-					return
-				case f := <-foundCh:
-					found[f] = true
-				}
-			}
-		}()
-		filter := []string{"ts", "tsx"}
-
-		in := NewInjector(l, CLI.Unused.Dir, true, "", CLI.IgnoreFilter, filter, regex, replacementFunc, nil)
-		err := in.Inject()
+		translationKeys, regex := buildTranslationMapWithRegex(cmd.Context(), l, source, *api, CLI.Project, CLI.Locale)
+		found, err := findUsedKeys(cmd.Context(), CLI.Unused.Dir, regex)
 		if err != nil {
-			l.Fatal().Err(err).Msg("Failed to inject")
+			fatal(err).Msg("Failed to inject")
 		}
-		quitCh <- struct{}{}
 		var unused []string
 		for k := range translationKeys {
 			if found[k] {
@@ -82,12 +65,32 @@ var unusedCmd = &cobra.Command{
 		fmt.Println(strings.Join(unused, "\n"))
 		if count > 0 {
 			l.Info().Int("count-unused", len(unused)).Msg("Found some possibly unused translation-keys")
+			os.Exit(ExitUnusedKeys)
 		} else {
 			l.Info().Msg("Found no unused translation-keys")
 		}
 	},
 }
 
+// sourceFileFilter are the extensions of the source-files scanned for translation-keys
+var sourceFileFilter = []string{"ts", "tsx"}
+
+// findUsedKeys scans the source-files within dir for the translation-keys matched by the regex,
+// as built by buildTranslationKeyRegexFromMap.
+func findUsedKeys(ctx context.Context, dir string, regex *regexp.Regexp) (map[string]bool, error) {
+	found := map[string]bool{}
+	lock := sync.Mutex{}
+	replacementFunc := func(groups []string) (replacement string, changed bool) {
+		lock.Lock()
+		found[groups[1]] = true
+		lock.Unlock()
+		return "", false
+	}
+	in := NewInjector(l, dir, true, "", CLI.IgnoreFilter, sourceFileFilter, regex, replacementFunc, nil)
+	err := in.Inject(ctx)
+	return found, err
+}
+
 func init() {
 	rootCmd.AddCommand(unusedCmd)
 	s := reflect.TypeOf(CLI.Unused)
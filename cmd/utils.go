@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -114,10 +115,13 @@ func runCmd(command string, fPath string, stdin io.Reader) ([]byte, error) {
 			Interface("args", args).
 			Msg("Running command on replacement")
 	}
-	out, err := c.CombinedOutput()
+	// stderr is kept separate, since the output of formatters like prettier is used as file-contents
+	stderr := &bytes.Buffer{}
+	c.Stderr = stderr
+	out, err := c.Output()
 	if err != nil {
-		l.Error().Err(err).Interface("command", &c).Str("output", string(out)).Msg("Failed to run command")
-		return out, fmt.Errorf("Failed to run onReplaceCmd %s %s %v: %w", c.Path, string(out), c.Args, err)
+		l.Error().Err(err).Interface("command", &c).Str("output", string(out)).Str("stderr", stderr.String()).Msg("Failed to run command")
+		return out, fmt.Errorf("Failed to run onReplaceCmd %s %s %v: %w", c.Path, stderr.String(), c.Args, err)
 	}
 	return out, nil
 }
@@ -126,26 +130,30 @@ func commandExists(cmd string) bool {
 	l.Debug().Str("cmd", cmd).Bool("found", err == nil).Msg("Checking for existance of command")
 	return err == nil
 }
+
+// runPrettier formats the contents with prettier, as if it was the file at filepath, and returns the result.
+// If contents is nil, the file is read. If prettier is not available, nil is returned.
 func runPrettier(filepath string, contents io.Reader) ([]byte, error) {
 	// TODO: also check yarn/npm/node-modules
+	var command string
 	if commandExists(CLI.PrettierDSlimPath) {
 		l.Debug().Msg("prettier_d_slim is available")
-		if contents == nil {
-			l.Debug().Str("path", filepath).Msg("Rereading from file")
-			f, err := os.Open(filepath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read the file-contents prior to running command")
-			}
-			contents = f
-			f.Close()
-		}
-		return runCmd(CLI.PrettierDSlimPath+" --stdin --stdin-filepath", filepath, contents)
-	}
-	if commandExists(CLI.PrettierPath) {
+		command = CLI.PrettierDSlimPath + " --stdin --stdin-filepath"
+	} else if commandExists(CLI.PrettierPath) {
 		l.Debug().Msg("prettier is available. Consider using prettier_d_slim if you want improved speed")
-		return runCmd(CLI.PrettierPath+" -w --ignore-path NOEXIST", filepath, contents)
+		command = CLI.PrettierPath + " --ignore-path NOEXIST --stdin-filepath"
+	} else {
+		return nil, nil
+	}
+	if contents == nil {
+		l.Debug().Str("path", filepath).Msg("Rereading from file")
+		b, err := os.ReadFile(filepath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the file-contents prior to running command: %w", err)
+		}
+		contents = bytes.NewReader(b)
 	}
-	return nil, nil
+	return runCmd(command, filepath, contents)
 }
 
 func ReplaceAllStringSubmatchFunc(re *regexp.Regexp, str string, repl func([]string, int, int) string) string {
@@ -164,3 +172,118 @@ func ReplaceAllStringSubmatchFunc(re *regexp.Regexp, str string, repl func([]str
 
 	return result + str[lastIndex:]
 }
+
+// closestMatches returns up to max of the candidates closest to s, by edit-distance.
+// Candidates that are too different from s are not included.
+func closestMatches(s string, candidates []string, max int) []string {
+	type scored struct {
+		value    string
+		distance int
+	}
+	var matches []scored
+	lower := strings.ToLower(s)
+	for _, c := range candidates {
+		cl := strings.ToLower(c)
+		d := levenshtein(lower, cl)
+		if strings.Contains(cl, lower) || strings.Contains(lower, cl) {
+			d = 0
+		}
+		if d > len(c)/2+1 {
+			continue
+		}
+		matches = append(matches, scored{c, d})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+	var result []string
+	for i := 0; i < len(matches) && i < max; i++ {
+		result = append(result, matches[i].value)
+	}
+	return result
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// canonicalize returns json and yaml (by the extension of the path) with sorted keys,
+// stable indentation and a trailing newline. Other content is returned as is.
+func canonicalize(path string, content []byte) ([]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(content))
+		// Numbers are kept as is, instead of being converted to float64
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return content, err
+		}
+		return marshalJSON(v)
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(content, &v); err != nil {
+			return content, err
+		}
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return content, err
+		}
+		if !bytes.HasSuffix(b, []byte("\n")) {
+			b = append(b, '\n')
+		}
+		return b, nil
+	}
+	return content, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory, and
+// renames it into place, so that the file is either written completely, or not at all.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	// After a successful rename, this is a no-op
+	defer os.Remove(tmp)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
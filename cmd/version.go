@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	markdown "github.com/MichaelMure/go-term-markdown"
+	"github.com/runar-rkmedia/skiver/models"
+	"github.com/spf13/cobra"
+)
+
+// versionCheckInterval is how often the server is asked about new versions.
+const versionCheckInterval = 24 * time.Hour
+
+type versionCheck struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Info      models.ServerInfo `json:"info"`
+}
+
+func versionCheckPath() (string, error) {
+	dir, err := defaultCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "version-check.json"), nil
+}
+
+// readVersionChecks reads the previous version-checks, keyed by endpoint
+func readVersionChecks() (map[string]versionCheck, error) {
+	checks := map[string]versionCheck{}
+	p, err := versionCheckPath()
+	if err != nil {
+		return checks, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return checks, nil
+		}
+		return checks, err
+	}
+	err = json.Unmarshal(b, &checks)
+	return checks, err
+}
+
+func writeVersionChecks(checks map[string]versionCheck) error {
+	p, err := versionCheckPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(checks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// Concurrent runs may write at the same time
+	return writeFileAtomic(p, b, 0644)
+}
+
+// cachedServerInfo returns the ServerInfo from the last check, if it is more recent than maxAge.
+// Otherwise, it is fetched from the server. fresh is true if it was fetched.
+// When replaying, the cache is neither read nor written.
+func (a *Api) cachedServerInfo(ctx context.Context, maxAge time.Duration) (info models.ServerInfo, fresh bool, err error) {
+	if a.Replaying {
+		info, err = a.ServerInfo(ctx)
+		return info, false, err
+	}
+	checks, err := readVersionChecks()
+	if err != nil {
+		a.l.Debug().Err(err).Msg("Failed to read previous version-checks")
+	}
+	if c, ok := checks[a.endpoint]; ok && time.Since(c.CheckedAt) < maxAge {
+		return c.Info, false, nil
+	}
+	info, err = a.ServerInfo(ctx)
+	if err != nil {
+		return info, false, err
+	}
+	checks[a.endpoint] = versionCheck{CheckedAt: time.Now(), Info: info}
+	if err := writeVersionChecks(checks); err != nil {
+		a.l.Debug().Err(err).Msg("Failed to write version-check")
+	}
+	return info, true, nil
+}
+
+// verifyLatestVersion checks the cli-version against the server, at most once per versionCheckInterval.
+// It exits if the cli is older than the servers MinCliVersion, and notifies about new releases on stderr.
+func (a *Api) verifyLatestVersion(ctx context.Context) {
+	if version == "" {
+		return
+	}
+	currentCli, err := semver.NewVersion(version)
+	if err != nil {
+		a.l.Debug().Err(err).Str("version", version).Msg("Failed to parse the version of the cli, skipping version-check")
+		return
+	}
+	info, fresh, err := a.cachedServerInfo(ctx, versionCheckInterval)
+	if err != nil {
+		a.l.Warn().Err(err).Msg("There was a problem fetching the server-info")
+		return
+	}
+	if info.Instance == "" && info.HostHash == "" {
+		fatalCodeWith(a.l.Logger, ExitUsage, nil).
+			Str("endpoint", a.endpoint).
+			Msg("This does not look like a Skiver-api...")
+	}
+
+	if info.MinCliVersion != "" {
+		if v, err := semver.NewVersion(info.MinCliVersion); err == nil {
+			if currentCli.LessThan(v) {
+				fatalCodeWith(a.l.Logger, ExitUnsupported, nil).
+					Str("version", currentCli.Original()).
+					Str("min-version", info.MinCliVersion).
+					Msg("This version of skiver-cli is not supported by the server. Please upgrade")
+			}
+		} else {
+			a.l.Warn().
+				Err(err).
+				Str("min-version", info.MinCliVersion).
+				Msg("There was a problem parsing the MinCliVersion for the server")
+		}
+	}
+	// Only notify about new releases when the info was fetched, to not nag on every invocation.
+	if !fresh {
+		return
+	}
+	if info.LatestCliRelease == nil || info.LatestCliRelease.TagName == "" {
+		return
+	}
+	v, err := semver.NewVersion(info.LatestCliRelease.TagName)
+	if err != nil {
+		a.l.Warn().
+			Err(err).
+			Str("newversion", info.LatestCliRelease.TagName).
+			Msg("There was a problem parsing the TagName for the release")
+		return
+	}
+	if !v.GreaterThan(currentCli) {
+		return
+	}
+	result := markdown.Render(fmt.Sprintf("---\n\n🎉🎉🎉 version %s of Skiver-CLI is available 🎉🎉🎉 \n\n%s\n\n---\n\nGet it from %s",
+		info.LatestCliRelease.TagName,
+		info.LatestCliRelease.Body,
+		info.LatestCliRelease.HTMLURL,
+	), 80, 2)
+	fmt.Fprintln(os.Stderr, string(result))
+}
+
+var checkVersions bool
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of the cli, and optionally of the server",
+	Args:  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "skiver-cli:\t%s\n", version)
+		if commit != "" {
+			fmt.Fprintf(w, "commit:\t%s\n", commit)
+		}
+		if !buildDate.IsZero() {
+			fmt.Fprintf(w, "built:\t%s\n", buildDate.Format(time.RFC3339))
+		}
+		if !checkVersions {
+			w.Flush()
+			return
+		}
+		api := newApiFromConfig(false)
+		info, _, err := api.cachedServerInfo(cmd.Context(), 0)
+		if err != nil {
+			w.Flush()
+			fatal(err).Msg("Failed to fetch the server-info")
+		}
+		fmt.Fprintf(w, "server:\t%s\n", info.Version)
+		if info.MinCliVersion != "" {
+			fmt.Fprintf(w, "server min cli-version:\t%s\n", info.MinCliVersion)
+		}
+		if info.LatestCliRelease != nil {
+			fmt.Fprintf(w, "latest cli-release:\t%s\n", info.LatestCliRelease.TagName)
+		}
+		if info.LatestRelease != nil {
+			fmt.Fprintf(w, "latest server-release:\t%s\n", info.LatestRelease.TagName)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&checkVersions, "check", false, "Also check the versions of the server and the latest releases")
+}
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -14,16 +14,10 @@ import (
 	"github.com/runar-rkmedia/skiver/utils"
 )
 
-func BuildTranslationKeyFromApi(api Api, l logger.AppLogger, projectKeyLike, localeLike string) map[string]map[string]string {
-	buf := bytes.Buffer{}
-	err := api.Export(projectKeyLike, "raw", localeLike, &buf)
+func BuildTranslationKeyFromApi(ctx context.Context, api Api, l logger.AppLogger, projectKeyLike, localeLike string) map[string]map[string]string {
+	ep, err := api.ExportProject(ctx, projectKeyLike, localeLike)
 	if err != nil {
-		l.Fatal().Err(err).Msg("Failed to get exported project")
-	}
-	var ep types.ExtendedProject
-	err = json.Unmarshal(buf.Bytes(), &ep)
-	if err != nil {
-		l.Fatal().Err(err).Msg("Failed to unmarshal exported project")
+		fatalExportError(ctx, l, api, projectKeyLike, err, "Failed to get exported project")
 	}
 	m, err := FlattenExtendedProject(ep, []string{localeLike})
 	if err != nil {
@@ -49,9 +43,27 @@ func buildTranslationKeyRegexFromMap(sorted []string) *regexp.Regexp {
 
 }
 
+// fatalExportError logs a fatal error for a failed export. If the project could
+// not be found, the closest matching project-names are suggested instead.
+func fatalExportError(ctx context.Context, l logger.AppLogger, api Api, project string, err error, msg string) {
+	if !api.Offline {
+		p, suggestions, rerr := api.ResolveProject(ctx, project)
+		if rerr == nil && p == nil {
+			if len(suggestions) == 0 {
+				fatalCodeWith(l.Logger, ExitNotFound, err).Str("project", project).Msg("Project not found")
+			}
+			fatalCodeWith(l.Logger, ExitNotFound, err).
+				Str("project", project).
+				Strs("suggestions", suggestions).
+				Msgf("Project not found. Did you mean: %s?", strings.Join(suggestions, ", "))
+		}
+	}
+	fatalWith(l.Logger, err).Str("project", project).Msg(msg)
+}
+
 // Creates a flattened map of translationKeys
 // The source can either be a file (i18next), or it will fallback to getting from the api
-func buildTranslationMapWithRegex(l logger.AppLogger, fromSourceFile *os.File, api Api, project, locale string) (map[string]struct{}, *regexp.Regexp) {
+func buildTranslationMapWithRegex(ctx context.Context, l logger.AppLogger, fromSourceFile *os.File, api Api, project, locale string) (map[string]struct{}, *regexp.Regexp) {
 	translationKeys := map[string]struct{}{}
 	// var r1 *regexp.Regexp
 	if fromSourceFile != nil {
@@ -73,7 +85,7 @@ func buildTranslationMapWithRegex(l logger.AppLogger, fromSourceFile *os.File, a
 			translationKeys[joined] = struct{}{}
 		}
 	} else {
-		mm := BuildTranslationKeyFromApi(api, l, project, locale)
+		mm := BuildTranslationKeyFromApi(ctx, api, l, project, locale)
 		for k := range mm {
 			translationKeys[k] = struct{}{}
 		}
@@ -129,7 +141,12 @@ func FlattenExtendedProject(ep types.ExtendedProject, locales []string) (map[str
 				if len(mm) == 0 {
 					continue
 				}
-				m[key] = mm
+				if m[key] == nil {
+					m[key] = map[string]string{}
+				}
+				for k, v := range mm {
+					m[key][k] = v
+				}
 			}
 		}
 	}
@@ -155,7 +172,12 @@ func FlattenExtendedProject(ep types.ExtendedProject, locales []string) (map[str
 				if len(mm) == 0 {
 					continue
 				}
-				m[key] = mm
+				if m[key] == nil {
+					m[key] = map[string]string{}
+				}
+				for k, v := range mm {
+					m[key][k] = v
+				}
 
 			}
 		}
@@ -193,3 +215,69 @@ func matchesLocale(l types.Locale, locales []string) string {
 
 	return ""
 }
+
+// findCategory returns the category with the (dot-delimited) key from within the tree.
+func findCategory(tree types.ExtendedCategory, key string) (types.ExtendedCategory, bool) {
+	if tree.Key == key {
+		return tree, true
+	}
+	for _, c := range tree.Categories {
+		if found, ok := findCategory(c, key); ok {
+			return found, true
+		}
+	}
+	return types.ExtendedCategory{}, false
+}
+
+// findLocale returns the locale within the project matching the locale-like string.
+func findLocale(ep types.ExtendedProject, localeLike string) (types.Locale, bool) {
+	for _, loc := range ep.Locales {
+		if matchesLocale(loc, []string{localeLike}) != "" {
+			return loc, true
+		}
+	}
+	return types.Locale{}, false
+}
+
+// splitTranslationKey splits a key like "foo.bar.baz" into the category-key "foo.bar" and the translation-key "baz"
+func splitTranslationKey(key string) (categoryKey string, translationKey string) {
+	i := strings.LastIndex(key, ".")
+	if i < 0 {
+		return "", key
+	}
+	return key[:i], key[i+1:]
+}
+
+// walkCategories calls fn for every category within the tree, including the tree itself.
+func walkCategories(tree types.ExtendedCategory, fn func(c types.ExtendedCategory)) {
+	fn(tree)
+	for _, c := range tree.Categories {
+		walkCategories(c, fn)
+	}
+}
+
+type projectStats struct {
+	Categories   int
+	Translations int
+	// Values is the number of translation-values per locale-id
+	Values map[string]int
+}
+
+func buildProjectStats(ep types.ExtendedProject) projectStats {
+	stats := projectStats{Values: map[string]int{}}
+	walkCategories(ep.CategoryTree, func(c types.ExtendedCategory) {
+		if c.Key != "" {
+			stats.Categories++
+		}
+		stats.Translations += len(c.Translations)
+		for _, t := range c.Translations {
+			for _, tv := range t.Values {
+				if tv.Value == "" && len(tv.Context) == 0 {
+					continue
+				}
+				stats.Values[tv.LocaleID]++
+			}
+		}
+	})
+	return stats
+}